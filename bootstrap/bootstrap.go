@@ -0,0 +1,111 @@
+// Package bootstrap resolves a rest.Config for the example programs.
+//
+// Instead of hard-coding ~/.kube/config, the examples walk a chain of
+// sources and take the first one that applies:
+//
+//  1. --kubeconfig flag (optionally with --context)
+//  2. KUBECONFIG env var
+//  3. ~/.kube/config
+//  4. in-cluster config (ServiceAccount token mounted into a Pod)
+//
+// so the same program runs on a laptop and inside a Pod unchanged. The
+// in-cluster config has no contexts, so it fails if --context is set.
+// Programs can also build their own Chain, e.g., putting an in-memory
+// kubeconfig (KubeconfigGetter) in front of the defaults.
+package bootstrap
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// ErrSkip is returned by a Source that doesn't apply to the current
+// environment (e.g., no KUBECONFIG set, or not running in a Pod).
+var ErrSkip = errors.New("source not applicable")
+
+// Source produces a rest.Config from a single place.
+type Source interface {
+	Name() string
+	Config() (*rest.Config, error)
+}
+
+// Chain tries sources in order and picks the first applicable one.
+type Chain []Source
+
+// Config returns the config produced by the first source that doesn't
+// return ErrSkip, along with that source's name. A source failing with
+// any other error stops the chain - a broken --kubeconfig must not
+// silently fall back to a different cluster.
+func (c Chain) Config() (*rest.Config, string, error) {
+	var skipped []string
+	for _, source := range c {
+		config, err := source.Config()
+		if errors.Is(err, ErrSkip) {
+			skipped = append(skipped, source.Name())
+			continue
+		}
+		if err != nil {
+			return nil, source.Name(), fmt.Errorf("%s: %w", source.Name(), err)
+		}
+		return config, source.Name(), nil
+	}
+	return nil, "", fmt.Errorf("no client config found (tried: %s)", strings.Join(skipped, ", "))
+}
+
+// Flags holds the command line flags understood by the default chain.
+type Flags struct {
+	Kubeconfig string
+	Context    string
+}
+
+func (f *Flags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.Kubeconfig, "kubeconfig", "", "path to the kubeconfig file")
+	fs.StringVar(&f.Context, "context", "", "name of the kubeconfig context to use")
+}
+
+// Chain returns the default chain of sources configured by the flags.
+func (f *Flags) Chain() Chain {
+	return Chain{
+		Kubeconfig(f.Kubeconfig, f.Context),
+		KubeconfigEnv(f.Context),
+		DefaultKubeconfig(f.Context),
+		withoutContext(InCluster(), f.Context),
+	}
+}
+
+// withoutContext fails a source that has no notion of contexts (like
+// InCluster) if a context is asked for anyway - the --context was meant
+// for a kubeconfig that hasn't been found, and ignoring it could silently
+// target a different cluster.
+func withoutContext(source Source, context string) Source {
+	return NewSource(source.Name(), func() (*rest.Config, error) {
+		config, err := source.Config()
+		if err == nil && context != "" {
+			return nil, fmt.Errorf("--context %q given, but there is no kubeconfig to take it from", context)
+		}
+		return config, err
+	})
+}
+
+// ConfigOrDie registers the --kubeconfig and --context flags on the
+// global flag set, parses the command line, and resolves the config
+// using the default chain. Programs with flags of their own must
+// define them before calling ConfigOrDie.
+func ConfigOrDie() *rest.Config {
+	flags := &Flags{}
+	flags.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	config, source, err := flags.Chain().Config()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	fmt.Fprintf(os.Stderr, "Using client config from %s\n", source)
+	return config
+}
@@ -0,0 +1,279 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeconfig returns a kubeconfig with two contexts - the current one
+// pointing to https://<name>.example.com and "other" pointing to
+// https://<name>-other.example.com.
+func kubeconfig(name string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+- name: %[1]s-other
+  cluster:
+    server: https://%[1]s-other.example.com
+users:
+- name: user
+  user:
+    token: token
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: user
+- name: other
+  context:
+    cluster: %[1]s-other
+    user: user
+current-context: %[1]s
+`, name)
+}
+
+func writeKubeconfig(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(kubeconfig(name)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFlagsChain(t *testing.T) {
+	dir := t.TempDir()
+	flagFile := writeKubeconfig(t, dir, "flag")
+	envFile := writeKubeconfig(t, dir, "env")
+	homeFile := writeKubeconfig(t, dir, "home")
+	missing := filepath.Join(dir, "missing")
+
+	// The real InCluster() needs the token mounted at a fixed path.
+	inCluster := NewSource("in-cluster config", func() (*rest.Config, error) {
+		return &rest.Config{Host: "https://kubernetes.default.svc"}, nil
+	})
+	notInCluster := NewSource("in-cluster config", func() (*rest.Config, error) {
+		return nil, ErrSkip
+	})
+
+	for _, tc := range []struct {
+		name      string
+		flags     Flags
+		env       string
+		home      string
+		inCluster Source
+		source    string
+		host      string
+		err       string
+	}{
+		{
+			name:      "flag wins",
+			flags:     Flags{Kubeconfig: flagFile},
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "--kubeconfig flag",
+			host:      "https://flag.example.com",
+		},
+		{
+			name:      "flag with context",
+			flags:     Flags{Kubeconfig: flagFile, Context: "other"},
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "--kubeconfig flag",
+			host:      "https://flag-other.example.com",
+		},
+		{
+			name:      "missing flag file doesn't fall back",
+			flags:     Flags{Kubeconfig: missing},
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "--kubeconfig flag",
+			err:       "--kubeconfig flag: ",
+		},
+		{
+			name:      "unknown context doesn't fall back",
+			flags:     Flags{Kubeconfig: flagFile, Context: "nope"},
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "--kubeconfig flag",
+			err:       "nope",
+		},
+		{
+			name:      "env var",
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "KUBECONFIG env var",
+			host:      "https://env.example.com",
+		},
+		{
+			name:      "env var with context",
+			flags:     Flags{Context: "other"},
+			env:       envFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "KUBECONFIG env var",
+			host:      "https://env-other.example.com",
+		},
+		{
+			name:      "env var merges files, the first one wins",
+			env:       missing + string(filepath.ListSeparator) + envFile + string(filepath.ListSeparator) + flagFile,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "KUBECONFIG env var",
+			host:      "https://env.example.com",
+		},
+		{
+			name:      "env var with missing files only",
+			env:       missing,
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "~/.kube/config",
+			host:      "https://home.example.com",
+		},
+		{
+			name:      "home",
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "~/.kube/config",
+			host:      "https://home.example.com",
+		},
+		{
+			name:      "home with context",
+			flags:     Flags{Context: "other"},
+			home:      homeFile,
+			inCluster: inCluster,
+			source:    "~/.kube/config",
+			host:      "https://home-other.example.com",
+		},
+		{
+			name:      "in-cluster",
+			home:      missing,
+			inCluster: inCluster,
+			source:    "in-cluster config",
+			host:      "https://kubernetes.default.svc",
+		},
+		{
+			name:      "in-cluster with context",
+			flags:     Flags{Context: "other"},
+			home:      missing,
+			inCluster: inCluster,
+			source:    "in-cluster config",
+			err:       `--context "other" given, but there is no kubeconfig to take it from`,
+		},
+		{
+			name:      "nothing applies",
+			home:      missing,
+			inCluster: notInCluster,
+			err:       "no client config found (tried: --kubeconfig flag, KUBECONFIG env var, ~/.kube/config, in-cluster config)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(clientcmd.RecommendedConfigPathEnvVar, tc.env)
+
+			defaultHome := clientcmd.RecommendedHomeFile
+			clientcmd.RecommendedHomeFile = tc.home
+			defer func() { clientcmd.RecommendedHomeFile = defaultHome }()
+
+			chain := tc.flags.Chain()
+			if name := chain[len(chain)-1].Name(); name != "in-cluster config" {
+				t.Fatalf("expected the chain to end with the in-cluster config, got %s", name)
+			}
+			chain[len(chain)-1] = withoutContext(tc.inCluster, tc.flags.Context)
+
+			config, source, err := chain.Config()
+			if source != tc.source {
+				t.Errorf("expected the source %q, got %q", tc.source, source)
+			}
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != tc.host {
+				t.Errorf("expected the host %q, got %q", tc.host, config.Host)
+			}
+		})
+	}
+}
+
+func TestInClusterNotInPod(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+	if _, err := InCluster().Config(); !errors.Is(err, ErrSkip) {
+		t.Errorf("expected ErrSkip outside of a Pod, got %v", err)
+	}
+}
+
+func TestKubeconfigYAML(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		context string
+		host    string
+		skip    bool
+		err     string
+	}{
+		{name: "current context", data: kubeconfig("yaml"), host: "https://yaml.example.com"},
+		{name: "explicit context", data: kubeconfig("yaml"), context: "other", host: "https://yaml-other.example.com"},
+		{name: "empty data", skip: true},
+		{name: "unknown context", data: kubeconfig("yaml"), context: "nope", err: "nope"},
+		{name: "malformed data", data: "{", err: "yaml"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := KubeconfigYAML("embedded kubeconfig", []byte(tc.data), tc.context)
+			if source.Name() != "embedded kubeconfig" {
+				t.Errorf("expected the given name, got %q", source.Name())
+			}
+
+			config, err := source.Config()
+			switch {
+			case tc.skip:
+				if !errors.Is(err, ErrSkip) {
+					t.Errorf("expected ErrSkip, got %v", err)
+				}
+			case tc.err != "":
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("expected an error containing %q, got %v", tc.err, err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case config.Host != tc.host:
+				t.Errorf("expected the host %q, got %q", tc.host, config.Host)
+			}
+		})
+	}
+
+	// An empty one lets the chain move on to the next source.
+	chain := Chain{
+		KubeconfigYAML("embedded kubeconfig", nil, ""),
+		KubeconfigYAML("fallback kubeconfig", []byte(kubeconfig("fallback")), ""),
+	}
+	config, source, err := chain.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "fallback kubeconfig" || config.Host != "https://fallback.example.com" {
+		t.Errorf("expected the fallback kubeconfig, got %s (%s)", source, config.Host)
+	}
+}
@@ -0,0 +1,120 @@
+package bootstrap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+type source struct {
+	name   string
+	config func() (*rest.Config, error)
+}
+
+func (s source) Name() string {
+	return s.name
+}
+
+func (s source) Config() (*rest.Config, error) {
+	return s.config()
+}
+
+// NewSource turns a function into a Source. The function should return
+// ErrSkip if it doesn't apply to the current environment.
+func NewSource(name string, config func() (*rest.Config, error)) Source {
+	return source{name: name, config: config}
+}
+
+// Kubeconfig reads an explicitly given kubeconfig file (the --kubeconfig flag).
+// An empty path skips the source, a missing file is an error.
+func Kubeconfig(path, context string) Source {
+	return NewSource("--kubeconfig flag", func() (*rest.Config, error) {
+		if path == "" {
+			return nil, ErrSkip
+		}
+		return loadingRulesConfig(&clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, context)
+	})
+}
+
+// KubeconfigEnv merges the files listed in the KUBECONFIG env var
+// exactly like kubectl does (the first file has the highest priority).
+func KubeconfigEnv(context string) Source {
+	return NewSource("KUBECONFIG env var", func() (*rest.Config, error) {
+		env := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+		if env == "" {
+			return nil, ErrSkip
+		}
+
+		config, err := loadingRulesConfig(
+			&clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(env)},
+			context,
+		)
+		if clientcmd.IsEmptyConfig(err) {
+			// None of the listed files exist.
+			return nil, ErrSkip
+		}
+		return config, err
+	})
+}
+
+// DefaultKubeconfig reads ~/.kube/config if it exists.
+func DefaultKubeconfig(context string) Source {
+	return NewSource("~/.kube/config", func() (*rest.Config, error) {
+		if _, err := os.Stat(clientcmd.RecommendedHomeFile); os.IsNotExist(err) {
+			return nil, ErrSkip
+		}
+		return loadingRulesConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: clientcmd.RecommendedHomeFile},
+			context,
+		)
+	})
+}
+
+// InCluster uses the ServiceAccount token and CA mounted into every Pod
+// and the KUBERNETES_SERVICE_HOST/PORT env vars.
+func InCluster() Source {
+	return NewSource("in-cluster config", func() (*rest.Config, error) {
+		config, err := rest.InClusterConfig()
+		if errors.Is(err, rest.ErrNotInCluster) {
+			return nil, ErrSkip
+		}
+		return config, err
+	})
+}
+
+// KubeconfigGetter builds the config from a kubeconfig obtained by
+// other means than reading a file (e.g., embedded into the binary or
+// fetched from a Secret). The getter may return ErrSkip.
+func KubeconfigGetter(name string, getter clientcmd.KubeconfigGetter, context string) Source {
+	return NewSource(name, func() (*rest.Config, error) {
+		kubeconfig, err := getter()
+		if err != nil {
+			return nil, err
+		}
+		return clientcmd.NewNonInteractiveClientConfig(
+			*kubeconfig, context, &clientcmd.ConfigOverrides{}, nil,
+		).ClientConfig()
+	})
+}
+
+// KubeconfigYAML is a KubeconfigGetter source for an in-memory kubeconfig.
+// Empty data skips the source.
+func KubeconfigYAML(name string, data []byte, context string) Source {
+	return KubeconfigGetter(name, func() (*api.Config, error) {
+		if len(data) == 0 {
+			return nil, ErrSkip
+		}
+		return clientcmd.Load(data)
+	}, context)
+}
+
+func loadingRulesConfig(rules *clientcmd.ClientConfigLoadingRules, context string) (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+}
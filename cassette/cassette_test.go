@@ -0,0 +1,213 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := newStandInServer()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder := NewRecorder()
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(recorder.Wrap)
+
+	recorded := watchCreateDelete(t, kubernetes.NewForConfigOrDie(config), 30)
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing must reach the server from now on.
+	server.Close()
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = &rest.Config{Host: "http://cassette.invalid", WrapTransport: replayer.Wrap}
+
+	// A different watch timeout must not break the matching.
+	replayed := watchCreateDelete(t, kubernetes.NewForConfigOrDie(config), 60)
+
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("replayed session differs:\nrecorded: %v\nreplayed: %v", recorded, replayed)
+	}
+	if unused := replayer.Unused(); len(unused) > 0 {
+		t.Errorf("recorded but not replayed: %v", unused)
+	}
+
+	// The session is over - further requests aren't in the cassette.
+	_, err = kubernetes.NewForConfigOrDie(config).CoreV1().ConfigMaps("default").Get(context.Background(), "foo", metav1.GetOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected an unrecorded request to fail, got %v", err)
+	}
+}
+
+func TestRequestKeyIgnoresWatchTimeout(t *testing.T) {
+	a, _ := http.NewRequest("GET", "https://a/api/v1/pods?watch=true&timeoutSeconds=300&timeout=5m0s&resourceVersion=1", nil)
+	b, _ := http.NewRequest("GET", "https://b/api/v1/pods?resourceVersion=1&timeoutSeconds=42&timeout=42s&watch=true", nil)
+
+	if requestKey(a.Method, a.URL) != requestKey(b.Method, b.URL) {
+		t.Errorf("expected %q and %q to match", a.URL, b.URL)
+	}
+}
+
+// watchCreateDelete is the "program" under test. Its observations are
+// what must be the same live and replayed.
+func watchCreateDelete(t *testing.T, client kubernetes.Interface, timeout int64) []string {
+	ctx := context.Background()
+	configMaps := client.CoreV1().ConfigMaps("default")
+
+	watcher, err := configMaps.Watch(ctx, metav1.ListOptions{TimeoutSeconds: &timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	var seen []string
+	next := func() {
+		select {
+		case event := <-watcher.ResultChan():
+			cm := event.Object.(*corev1.ConfigMap)
+			seen = append(seen, fmt.Sprintf("%s %s rv=%s", event.Type, cm.Name, cm.ResourceVersion))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a watch event")
+		}
+	}
+
+	// The replayed stream must not run ahead of the program.
+	select {
+	case event := <-watcher.ResultChan():
+		t.Fatalf("unexpected watch event before any changes: %v", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for i := 0; i < 2; i++ {
+		cm, err := configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "cassette-"},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, "created "+cm.Name)
+		next()
+
+		if err := configMaps.Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		next()
+	}
+
+	return seen
+}
+
+// standInServer is just enough of an API server for watchCreateDelete.
+type standInServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	rv       int
+	watchers []chan watch.Event
+}
+
+func newStandInServer() *standInServer {
+	s := &standInServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *standInServer) serve(w http.ResponseWriter, r *http.Request) {
+	const collection = "/api/v1/namespaces/default/configmaps"
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == collection && r.URL.Query().Get("watch") == "true":
+		events := make(chan watch.Event, 10)
+		s.mu.Lock()
+		s.watchers = append(s.watchers, events)
+		s.mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case event := <-events:
+				object, _ := json.Marshal(event.Object)
+				json.NewEncoder(w).Encode(metav1.WatchEvent{
+					Type:   string(event.Type),
+					Object: runtime.RawExtension{Raw: object},
+				})
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+	case r.Method == "POST" && r.URL.Path == collection:
+		var cm corev1.ConfigMap
+		json.NewDecoder(r.Body).Decode(&cm)
+
+		cm.APIVersion, cm.Kind = "v1", "ConfigMap"
+		cm.Namespace = "default"
+		cm.Name = cm.GenerateName + s.nextRV()
+		cm.ResourceVersion = cm.Name[len(cm.GenerateName):]
+		s.notify(watch.Added, &cm)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&cm)
+
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, collection+"/"):
+		cm := corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: strings.TrimPrefix(r.URL.Path, collection+"/"), Namespace: "default", ResourceVersion: s.nextRV()},
+		}
+		s.notify(watch.Deleted, &cm)
+
+		json.NewEncoder(w).Encode(metav1.Status{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+			Status:   metav1.StatusSuccess,
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *standInServer) nextRV() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rv++
+	return fmt.Sprint(s.rv)
+}
+
+func (s *standInServer) notify(eventType watch.EventType, cm *corev1.ConfigMap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.watchers {
+		w <- watch.Event{Type: eventType, Object: cm.DeepCopy()}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/iximiuz/client-go-examples/cassette
+
+go 1.22.10
+
+require (
+	github.com/iximiuz/client-go-examples/bootstrap v0.0.0
+	k8s.io/api v0.30.1
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+)
+
+replace github.com/iximiuz/client-go-examples/bootstrap => ../bootstrap
@@ -0,0 +1,126 @@
+package cassette
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Recorder captures the traffic going through the transports it wraps.
+//
+//	recorder := cassette.NewRecorder()
+//	config.Wrap(recorder.Wrap)
+//	...
+//	recorder.Save("testdata/cassettes/v0.30.1/TestRun.json")
+//
+// Failed round trips (connection errors and alike) aren't recorded.
+type Recorder struct {
+	mu       sync.Mutex
+	cassette Cassette
+	sent     int // including the failed ones
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{cassette: Cassette{ClientGoVersion: ClientGoVersion()}}
+}
+
+// Wrap has the signature of rest.Config.WrapTransport.
+func (r *Recorder) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return r.roundTrip(rt, req)
+	})
+}
+
+func (r *Recorder) roundTrip(rt http.RoundTripper, req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.sent++
+	r.mu.Unlock()
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+			Body:   string(reqBody),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+		},
+	}
+
+	if isWatch(req) {
+		// The stream is written down as the program reads it.
+		resp.Body = &recordingStream{ReadCloser: resp.Body, recorder: r, interaction: interaction}
+	} else {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.Response.Body = string(body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Save writes down everything recorded so far. Watch streams that are
+// still open are saved as is.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cassette.Save(path)
+}
+
+type recordingStream struct {
+	io.ReadCloser
+	recorder    *Recorder
+	interaction *Interaction
+}
+
+func (s *recordingStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	if n > 0 {
+		s.interaction.Response.Chunks = append(s.interaction.Response.Chunks, Chunk{
+			After: s.recorder.sent,
+			Data:  string(p[:n]),
+		})
+	}
+	if err == io.EOF {
+		s.interaction.Response.EOF = true
+	}
+	return n, err
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,182 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replayer is an http.RoundTripper serving the responses from a cassette.
+//
+// Requests are matched by the method, path, and query. Identical requests
+// get the recorded responses in the recorded order. A request that wasn't
+// recorded (or was repeated more times than recorded) fails.
+type Replayer struct {
+	mu       sync.Mutex
+	queues   map[string][]*Interaction
+	received int
+	progress chan struct{} // closed and replaced on every received request
+}
+
+func NewReplayer(c *Cassette) (*Replayer, error) {
+	r := &Replayer{
+		queues:   map[string][]*Interaction{},
+		progress: make(chan struct{}),
+	}
+
+	for _, interaction := range c.Interactions {
+		u, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		key := requestKey(interaction.Request.Method, u)
+		r.queues[key] = append(r.queues[key], interaction)
+	}
+	return r, nil
+}
+
+// Wrap has the signature of rest.Config.WrapTransport. The wrapped
+// transport is never used.
+func (r *Replayer) Wrap(http.RoundTripper) http.RoundTripper {
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := requestKey(req.Method, req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Unmatched requests count too - they may have failed during
+	// the recording as well.
+	r.received++
+	close(r.progress)
+	r.progress = make(chan struct{})
+
+	queue := r.queues[key]
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("cassette: no recorded response for %s", key)
+	}
+	interaction := queue[0]
+	r.queues[key] = queue[1:]
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Response.Header.Clone(),
+		ContentLength: -1,
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+
+	if isWatch(req) {
+		resp.Body = &replayStream{
+			ctx:      req.Context(),
+			replayer: r,
+			response: &interaction.Response,
+			closed:   make(chan struct{}),
+		}
+	} else {
+		resp.Body = io.NopCloser(strings.NewReader(interaction.Response.Body))
+	}
+	return resp, nil
+}
+
+// Unused returns the recorded requests that haven't been replayed.
+func (r *Replayer) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []string
+	for key, queue := range r.queues {
+		for range queue {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// progressed reports whether the program has sent at least n requests.
+// If not, it returns a channel closed on the next request.
+func (r *Replayer) progressed(n int) (bool, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.received >= n {
+		return true, nil
+	}
+	return false, r.progress
+}
+
+type replayStream struct {
+	ctx      context.Context
+	replayer *Replayer
+	response *Response
+
+	chunk  int // the next chunk to send
+	offset int // the part of the chunk already sent
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (s *replayStream) Read(p []byte) (int, error) {
+	for {
+		if s.chunk == len(s.response.Chunks) {
+			if s.response.EOF {
+				return 0, io.EOF
+			}
+
+			// The server didn't end the stream - keep it open until
+			// the program stops the watch.
+			select {
+			case <-s.closed:
+				return 0, io.EOF
+			case <-s.ctx.Done():
+				return 0, s.ctx.Err()
+			}
+		}
+
+		chunk := s.response.Chunks[s.chunk]
+
+		// Hold the chunk back until the program catches up.
+		if ready, progress := s.replayer.progressed(chunk.After); !ready {
+			select {
+			case <-progress:
+				continue
+			case <-s.closed:
+				return 0, io.EOF
+			case <-s.ctx.Done():
+				return 0, s.ctx.Err()
+			}
+		}
+
+		n := copy(p, chunk.Data[s.offset:])
+		s.offset += n
+		if s.offset == len(chunk.Data) {
+			s.chunk++
+			s.offset = 0
+		}
+		return n, nil
+	}
+}
+
+func (s *replayStream) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
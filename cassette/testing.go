@@ -0,0 +1,71 @@
+package cassette
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"k8s.io/client-go/rest"
+)
+
+// RecordEnv switches the tests using Config to the recording mode.
+const RecordEnv = "CASSETTE_RECORD"
+
+func Recording() bool {
+	return os.Getenv(RecordEnv) == "1"
+}
+
+// Config returns the rest.Config for a test, backed by the cassette
+// <dir>/<client-go version>/<test name>.json.
+//
+// With CASSETTE_RECORD=1, the config points to the cluster picked by the
+// default bootstrap chain (KUBECONFIG, ~/.kube/config, etc.) and the
+// traffic is (re-)recorded when the test ends. Otherwise, the cassette is
+// replayed, and the test is skipped if it hasn't been recorded for the
+// current client-go version yet.
+func Config(t testing.TB, dir string) *rest.Config {
+	t.Helper()
+
+	path := filepath.Join(dir, ClientGoVersion(), t.Name()+".json")
+
+	if Recording() {
+		config, source, err := (&bootstrap.Flags{}).Chain().Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("Recording %s using client config from %s", path, source)
+
+		recorder := NewRecorder()
+		config.Wrap(recorder.Wrap)
+		t.Cleanup(func() {
+			if err := recorder.Save(path); err != nil {
+				t.Error(err)
+			}
+		})
+		return config
+	}
+
+	c, err := Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("No cassette %s - record it against a cluster with %s=1", path, RecordEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if unused := replayer.Unused(); len(unused) > 0 {
+			t.Logf("Recorded but not replayed: %v", unused)
+		}
+	})
+
+	// The host doesn't matter - no request leaves the process.
+	return &rest.Config{Host: "http://cassette.invalid", WrapTransport: replayer.Wrap}
+}
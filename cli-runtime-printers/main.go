@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -15,53 +17,61 @@ func main() {
 	}
 	obj.Name = "my-cm"
 
+	if err := printAll(os.Stdout, obj); err != nil {
+		panic(err.Error())
+	}
+}
+
+func printAll(w io.Writer, obj runtime.Object) error {
 	// YAML
-	fmt.Println("# YAML ConfigMap representation")
+	fmt.Fprintln(w, "# YAML ConfigMap representation")
 	printr := printers.NewTypeSetter(scheme.Scheme).ToPrinter(&printers.YAMLPrinter{})
-	if err := printr.PrintObj(obj, os.Stdout); err != nil {
-		panic(err.Error())
+	if err := printr.PrintObj(obj, w); err != nil {
+		return err
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// JSON
-	fmt.Println("# JSON ConfigMap representation")
+	fmt.Fprintln(w, "# JSON ConfigMap representation")
 	printr = printers.NewTypeSetter(scheme.Scheme).ToPrinter(&printers.JSONPrinter{})
-	if err := printr.PrintObj(obj, os.Stdout); err != nil {
-		panic(err.Error())
+	if err := printr.PrintObj(obj, w); err != nil {
+		return err
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Table (human-readable)
-	fmt.Println("# Table ConfigMap representation")
+	fmt.Fprintln(w, "# Table ConfigMap representation")
 	printr = printers.NewTypeSetter(scheme.Scheme).ToPrinter(printers.NewTablePrinter(printers.PrintOptions{}))
-	if err := printr.PrintObj(obj, os.Stdout); err != nil {
-		panic(err.Error())
+	if err := printr.PrintObj(obj, w); err != nil {
+		return err
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// JSONPath
-	fmt.Println("# ConfigMap.data.foo")
+	fmt.Fprintln(w, "# ConfigMap.data.foo")
 	printr, err := printers.NewJSONPathPrinter("{.data.foo}")
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
 	printr = printers.NewTypeSetter(scheme.Scheme).ToPrinter(printr)
-	if err := printr.PrintObj(obj, os.Stdout); err != nil {
-		panic(err.Error())
+	if err := printr.PrintObj(obj, w); err != nil {
+		return err
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Name-only
-	fmt.Println("# <kind>/<name>")
+	fmt.Fprintln(w, "# <kind>/<name>")
 	printr = printers.NewTypeSetter(scheme.Scheme).ToPrinter(&printers.NamePrinter{})
-	if err := printr.PrintObj(obj, os.Stdout); err != nil {
-		panic(err.Error())
+	if err := printr.PrintObj(obj, w); err != nil {
+		return err
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
+
+	return nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func TestPrintAll(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		obj  *corev1.ConfigMap
+	}{
+		{
+			name: "simple",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cm"},
+				Data:       map[string]string{"foo": "bar"},
+			},
+		},
+		{
+			// No data at all - the JSONPath printer fails on the missing key.
+			name: "empty",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cm"},
+			},
+		},
+		{
+			name: "binary-data",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cm"},
+				Data:       map[string]string{"foo": "bar"},
+				BinaryData: map[string][]byte{"blob": {0x00, 0x01, 0xfe, 0xff}},
+			},
+		},
+		{
+			name: "unicode",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "my-cm",
+					Namespace:   "default",
+					Annotations: map[string]string{"description": "конфиг <b>&</b>"},
+				},
+				Data: map[string]string{"foo": "🙂 ✓", "ключ": "значение"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printAll(&out, tc.obj); err != nil {
+				fmt.Fprintf(&out, "error: %v\n", err)
+			}
+
+			golden.Assert(t, tc.name, out.Bytes())
+		})
+	}
+}
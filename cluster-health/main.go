@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// go run main.go
+// go run main.go --context kind-shared2 -timeout 2s
+// go run main.go --kubeconfig /path/to/kubeconfig && echo healthy
+
+// Exit codes suitable for scripting.
+const (
+	exitHealthy     = 0
+	exitUnhealthy   = 1 // the API server responded, but some checks fail
+	exitUnreachable = 2 // no response (connection, TLS, auth, timeout)
+)
+
+type Check struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+type EndpointHealth struct {
+	Path       string
+	StatusCode int
+	Latency    time.Duration
+	Checks     []Check
+}
+
+func (h EndpointHealth) Healthy() bool {
+	if h.StatusCode != 200 {
+		return false
+	}
+	for _, check := range h.Checks {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout for the whole probe")
+	config := bootstrap.ConfigOrDie()
+
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	fmt.Printf("API server: %s\n", config.Host)
+	os.Exit(run(ctx, client.RESTClient(), os.Stdout))
+}
+
+// run probes /livez and /readyz, prints the results, and returns the
+// exit code.
+func run(ctx context.Context, client rest.Interface, out io.Writer) int {
+	// The TLS handshake happens only once - the second request
+	// reuses the connection established by the first one.
+	var handshake time.Duration
+	ctx = withTLSHandshakeTrace(ctx, &handshake)
+
+	start := time.Now()
+	exitCode := exitHealthy
+	for _, path := range []string{"/livez", "/readyz"} {
+		health, err := probe(ctx, client, path)
+		if err != nil {
+			fmt.Fprintf(out, "%s: unreachable: %v\n", path, err)
+			exitCode = exitUnreachable
+			continue
+		}
+
+		printHealth(out, health)
+		if !health.Healthy() && exitCode == exitHealthy {
+			exitCode = exitUnhealthy
+		}
+	}
+
+	if handshake > 0 {
+		fmt.Fprintf(out, "TLS handshake: %s\n", handshake.Round(time.Microsecond))
+	}
+	fmt.Fprintf(out, "Total latency: %s\n", time.Since(start).Round(time.Microsecond))
+
+	return exitCode
+}
+
+// probe queries a health endpoint in the verbose mode that lists
+// every individual check:
+//
+//	[+]ping ok
+//	[+]etcd ok
+//	[-]poststarthook/rbac/bootstrap-roles failed: reason withheld
+//	readyz check failed
+func probe(ctx context.Context, client rest.Interface, path string) (EndpointHealth, error) {
+	health := EndpointHealth{Path: path}
+
+	start := time.Now()
+	result := client.Get().AbsPath(path).Param("verbose", "").Do(ctx)
+	health.Latency = time.Since(start)
+
+	// A failing check makes the endpoint respond with 500, which client-go
+	// turns into an error. The body is still there, though.
+	result.StatusCode(&health.StatusCode)
+	body, err := result.Raw()
+	if health.StatusCode == 0 {
+		return health, err
+	}
+	if err != nil && health.StatusCode != 500 {
+		return health, err
+	}
+
+	health.Checks = parseChecks(string(body))
+	return health, nil
+}
+
+func parseChecks(body string) []Check {
+	var checks []Check
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var ok bool
+		switch {
+		case strings.HasPrefix(line, "[+]"):
+			ok = true
+		case strings.HasPrefix(line, "[-]"):
+			ok = false
+		default:
+			continue
+		}
+
+		name, message, _ := strings.Cut(line[3:], " ")
+		checks = append(checks, Check{Name: name, OK: ok, Message: message})
+	}
+
+	return checks
+}
+
+func withTLSHandshakeTrace(ctx context.Context, handshake *time.Duration) context.Context {
+	var start time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			start = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			*handshake = time.Since(start)
+		},
+	})
+}
+
+func printHealth(out io.Writer, health EndpointHealth) {
+	status := "ok"
+	if !health.Healthy() {
+		status = "FAILED"
+	}
+	fmt.Fprintf(out, "%s: %s (HTTP %d, %s)\n", health.Path, status, health.StatusCode, health.Latency.Round(time.Microsecond))
+
+	for _, check := range health.Checks {
+		mark := "+"
+		if !check.OK {
+			mark = "-"
+		}
+		fmt.Fprintf(out, "  [%s] %s %s\n", mark, check.Name, check.Message)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	healthyBody = `[+]ping ok
+[+]etcd ok
+[+]poststarthook/rbac/bootstrap-roles ok
+livez check passed
+`
+	unhealthyBody = `[+]ping ok
+[-]etcd failed: reason withheld
+[+]poststarthook/rbac/bootstrap-roles ok
+readyz check failed
+`
+)
+
+// endpoint is how the fake API server responds to a health endpoint.
+type endpoint struct {
+	status int
+	body   string
+}
+
+// newClient returns a client for a TLS server standing in for the API
+// server's health endpoints (/livez?verbose, /readyz?verbose).
+func newClient(t *testing.T, endpoints map[string]endpoint) (rest.Interface, *httptest.Server) {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint, ok := endpoints[r.URL.Path]
+		if !ok || !r.URL.Query().Has("verbose") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(endpoint.status)
+		w.Write([]byte(endpoint.body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := discovery.NewDiscoveryClientForConfig(&rest.Config{
+		Host: server.URL,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.RESTClient(), server
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		endpoints map[string]endpoint
+		closed    bool
+		exitCode  int
+		output    []string
+	}{
+		{
+			name: "healthy",
+			endpoints: map[string]endpoint{
+				"/livez":  {200, healthyBody},
+				"/readyz": {200, healthyBody},
+			},
+			exitCode: exitHealthy,
+			output: []string{
+				"/livez: ok (HTTP 200, ",
+				"/readyz: ok (HTTP 200, ",
+				"  [+] etcd ok\n",
+			},
+		},
+		{
+			name: "failing check",
+			endpoints: map[string]endpoint{
+				"/livez":  {200, healthyBody},
+				"/readyz": {500, unhealthyBody},
+			},
+			exitCode: exitUnhealthy,
+			output: []string{
+				"/livez: ok (HTTP 200, ",
+				"/readyz: FAILED (HTTP 500, ",
+				"  [-] etcd failed: reason withheld\n",
+			},
+		},
+		{
+			name: "unauthorized",
+			endpoints: map[string]endpoint{
+				"/livez":  {401, "Unauthorized"},
+				"/readyz": {401, "Unauthorized"},
+			},
+			exitCode: exitUnreachable,
+			output: []string{
+				"/livez: unreachable: ",
+				"/readyz: unreachable: ",
+			},
+		},
+		{
+			name:     "no server",
+			closed:   true,
+			exitCode: exitUnreachable,
+			output: []string{
+				"/livez: unreachable: ",
+				"/readyz: unreachable: ",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := newClient(t, tc.endpoints)
+			if tc.closed {
+				server.Close()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var out bytes.Buffer
+			if exitCode := run(ctx, client, &out); exitCode != tc.exitCode {
+				t.Errorf("expected exit code %d, got %d", tc.exitCode, exitCode)
+			}
+
+			for _, want := range tc.output {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected %q in the output, got:\n%s", want, out.String())
+				}
+			}
+
+			if tc.closed {
+				return
+			}
+
+			// The handshake is traced on the first request only.
+			m := regexp.MustCompile(`TLS handshake: (\S+)\n`).FindStringSubmatch(out.String())
+			if m == nil {
+				t.Fatalf("expected the TLS handshake time in the output, got:\n%s", out.String())
+			}
+			if handshake, err := time.ParseDuration(m[1]); err != nil || handshake <= 0 {
+				t.Errorf("expected a non-zero TLS handshake time, got %s", m[1])
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	client, _ := newClient(t, map[string]endpoint{
+		"/readyz": {500, unhealthyBody},
+	})
+
+	health, err := probe(context.Background(), client, "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.StatusCode != 500 || health.Healthy() {
+		t.Errorf("expected an unhealthy HTTP 500, got %+v", health)
+	}
+
+	want := []Check{
+		{Name: "ping", OK: true, Message: "ok"},
+		{Name: "etcd", OK: false, Message: "failed: reason withheld"},
+		{Name: "poststarthook/rbac/bootstrap-roles", OK: true, Message: "ok"},
+	}
+	if !reflect.DeepEqual(health.Checks, want) {
+		t.Errorf("expected the checks %+v, got %+v", want, health.Checks)
+	}
+}
+
+func TestParseChecks(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want []Check
+	}{
+		{"", nil},
+		{"ok", nil},
+		{"[+]ping ok\n", []Check{{Name: "ping", OK: true, Message: "ok"}}},
+		{"[-]etcd failed: reason withheld\n", []Check{{Name: "etcd", OK: false, Message: "failed: reason withheld"}}},
+		{"[+]informer-sync\n", []Check{{Name: "informer-sync", OK: true}}},
+		{"readyz check passed\n[+]ping ok", []Check{{Name: "ping", OK: true, Message: "ok"}}},
+	} {
+		if got := parseChecks(tc.body); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseChecks(%q): expected %+v, got %+v", tc.body, tc.want, got)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
@@ -24,22 +28,34 @@ func main() {
 		},
 	}
 
-	// Unstructured -> Typed
-	var tConfigMap corev1.ConfigMap
-	err := runtime.DefaultUnstructuredConverter.FromUnstructured(uConfigMap.Object, &tConfigMap)
+	tConfigMap, err := convert(os.Stdout, &uConfigMap)
 	if err != nil {
 		panic(err.Error())
 	}
 	if tConfigMap.GetName() != "my-configmap" {
 		panic("Typed config map has unexpected data")
 	}
+}
+
+// convert turns the unstructured object into a typed one and back.
+func convert(w io.Writer, uConfigMap *unstructured.Unstructured) (*corev1.ConfigMap, error) {
+	// Unstructured -> Typed
+	var tConfigMap corev1.ConfigMap
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(uConfigMap.Object, &tConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "Typed %#v\n", tConfigMap)
 
 	// Typed -> Unstructured
 	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&tConfigMap)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-	if !reflect.DeepEqual(unstructured.Unstructured{Object: object}, uConfigMap) {
-		panic("Unstructured config map has unexpected data")
+	fmt.Fprintf(w, "Unstructured %#v\n", object)
+
+	if !reflect.DeepEqual(unstructured.Unstructured{Object: object}, *uConfigMap) {
+		return nil, errors.New("unstructured config map has unexpected data")
 	}
+	return &tConfigMap, nil
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func init() {
+	// Converted timestamps are in the local time zone.
+	time.Local = time.UTC
+}
+
+func TestConvert(t *testing.T) {
+	configMap := func(metadata, rest map[string]interface{}) *unstructured.Unstructured {
+		obj := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   metadata,
+		}
+		for k, v := range rest {
+			obj[k] = v
+		}
+		return &unstructured.Unstructured{Object: obj}
+	}
+
+	for _, tc := range []struct {
+		name string
+		obj  *unstructured.Unstructured
+	}{
+		{
+			name: "simple",
+			obj: configMap(
+				map[string]interface{}{"creationTimestamp": nil, "namespace": "default", "name": "my-configmap"},
+				map[string]interface{}{"data": map[string]interface{}{"foo": "bar"}},
+			),
+		},
+		{
+			// The typed object always has a creationTimestamp, and it turns
+			// into an explicit null - the round trip isn't lossless.
+			name: "no-creation-timestamp",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap"},
+				map[string]interface{}{"data": map[string]interface{}{"foo": "bar"}},
+			),
+		},
+		{
+			name: "creation-timestamp",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": "2024-02-29T12:30:00Z"},
+				nil,
+			),
+		},
+		{
+			// The empty map is omitted from the typed object's JSON - another
+			// lossy round trip.
+			name: "empty-data",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": nil},
+				map[string]interface{}{"data": map[string]interface{}{}},
+			),
+		},
+		{
+			name: "binary-data",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": nil},
+				map[string]interface{}{"binaryData": map[string]interface{}{"blob": "AAH+/w=="}},
+			),
+		},
+		{
+			name: "unicode",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": nil},
+				map[string]interface{}{"data": map[string]interface{}{"ключ": "значение", "emoji": "🙂"}},
+			),
+		},
+		{
+			name: "wrong-value-type",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": nil},
+				map[string]interface{}{"data": map[string]interface{}{"int": int64(42)}},
+			),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if _, err := convert(&out, tc.obj); err != nil {
+				fmt.Fprintf(&out, "error: %v\n", err)
+			}
+
+			golden.Assert(t, tc.name, out.Bytes())
+		})
+	}
+}
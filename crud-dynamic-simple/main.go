@@ -2,35 +2,42 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path"
+	"flag"
 	"reflect"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("crud-dynamic-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "crud-dynamic-simple"), config)
+	defer lc.Finish()
 
 	client, err := dynamic.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	namespace := "default"
+	run(ctx, client, "default")
+}
+
+func run(ctx context.Context, client dynamic.Interface, namespace string) {
+	logger := klog.FromContext(ctx)
+
 	res := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}
 
 	desired := &unstructured.Unstructured{
@@ -40,6 +47,9 @@ func main() {
 			"metadata": map[string]interface{}{
 				"namespace":    namespace,
 				"generateName": "crud-dynamic-simple-",
+				"labels": map[string]interface{}{
+					"example": "crud-dynamic-simple",
+				},
 			},
 			"data": map[string]interface{}{
 				"foo": "bar",
@@ -51,12 +61,17 @@ func main() {
 	created, err := client.
 		Resource(res).
 		Namespace(namespace).
-		Create(context.Background(), desired, metav1.CreateOptions{})
+		Create(ctx, desired, metav1.CreateOptions{})
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", namespace, created.GetName())
+	logger.Info("Created ConfigMap", "configMap", klog.KObj(created), "resourceVersion", created.GetResourceVersion())
+
+	// Deleted below, unless the program panics or gets interrupted midway.
+	lifecycle.Track(ctx, "ConfigMap", created, func(ctx context.Context) error {
+		return client.Resource(res).Namespace(namespace).Delete(ctx, created.GetName(), metav1.DeleteOptions{})
+	})
 
 	data, _, _ := unstructured.NestedStringMap(created.Object, "data")
 	if !reflect.DeepEqual(map[string]string{"foo": "bar"}, data) {
@@ -68,7 +83,7 @@ func main() {
 		Resource(res).
 		Namespace(namespace).
 		Get(
-			context.Background(),
+			ctx,
 			created.GetName(),
 			metav1.GetOptions{},
 		)
@@ -76,7 +91,7 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Read ConfigMap %s/%s\n", namespace, read.GetName())
+	logger.Info("Read ConfigMap", "configMap", klog.KObj(read), "resourceVersion", read.GetResourceVersion())
 
 	data, _, _ = unstructured.NestedStringMap(read.Object, "data")
 	if !reflect.DeepEqual(map[string]string{"foo": "bar"}, data) {
@@ -89,7 +104,7 @@ func main() {
 		Resource(res).
 		Namespace(namespace).
 		Update(
-			context.Background(),
+			ctx,
 			read,
 			metav1.UpdateOptions{},
 		)
@@ -97,7 +112,7 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Updated ConfigMap %s/%s\n", namespace, updated.GetName())
+	logger.Info("Updated ConfigMap", "configMap", klog.KObj(updated), "resourceVersion", updated.GetResourceVersion())
 
 	data, _, _ = unstructured.NestedStringMap(updated.Object, "data")
 	if !reflect.DeepEqual(map[string]string{"foo": "qux"}, data) {
@@ -109,12 +124,12 @@ func main() {
 		Resource(res).
 		Namespace(namespace).
 		Delete(
-			context.Background(),
+			ctx,
 			created.GetName(),
 			metav1.DeleteOptions{},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
-	fmt.Printf("Deleted ConfigMap %s/%s\n", namespace, created.GetName())
+	logger.Info("Deleted ConfigMap", "configMap", klog.KObj(created))
 }
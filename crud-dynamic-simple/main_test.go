@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRun(t *testing.T) {
+	client := fakeclient.NewDynamicClient()
+
+	run(context.Background(), client, "default")
+
+	var verbs []string
+	for _, action := range client.Actions() {
+		verbs = append(verbs, action.GetVerb())
+	}
+	if want := []string{"create", "get", "update", "delete"}; !reflect.DeepEqual(verbs, want) {
+		t.Errorf("unexpected API calls: got %v, want %v", verbs, want)
+	}
+
+	list, err := client.
+		Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).
+		Namespace("default").
+		List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMap to be deleted, found %d ConfigMaps", len(list.Items))
+	}
+}
@@ -2,45 +2,87 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
-	"path"
 	"reflect"
+	"time"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	"github.com/iximiuz/client-go-examples/middleware"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"k8s.io/klog/v2"
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("crud-typed-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	logHTTP := flag.Bool("log-http", false, "log every HTTP request and response (with the credentials masked)")
+
+	config := bootstrap.ConfigOrDie()
+	metrics := wrapTransport(config, *logHTTP)
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "crud-typed-simple"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	namespace := "default"
+	run(ctx, client, "default")
+
+	metrics.Print(os.Stdout)
+}
+
+// wrapTransport makes every request carry an X-Request-Id and an
+// X-Client-Go-Example header, counts the requests by verb and resource,
+// and, with --log-http, logs them - headers included.
+func wrapTransport(config *rest.Config, logHTTP bool) *middleware.Metrics {
+	metrics := middleware.NewMetrics()
+
+	wrappers := []transport.WrapperFunc{
+		middleware.RequestID,
+		middleware.Headers(http.Header{"X-Client-Go-Example": {"crud-typed-simple"}}),
+		metrics.Wrap,
+	}
+	if logHTTP {
+		wrappers = append(wrappers, middleware.Logging)
+	}
+	middleware.Wrap(config, wrappers...)
+
+	return metrics
+}
+
+// run is separated from main() to be testable against a fake client.
+func run(ctx context.Context, client kubernetes.Interface, namespace string) {
+	logger := klog.FromContext(ctx)
 
 	desired := corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
 	desired.Namespace = namespace
 	desired.GenerateName = "crud-typed-simple-"
+	desired.SetLabels(map[string]string{"example": "crud-typed-simple"})
 
 	// Create
 	created, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			&desired,
 			metav1.CreateOptions{},
 		)
@@ -48,7 +90,12 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", namespace, created.GetName())
+	logger.Info("Created ConfigMap", "configMap", klog.KObj(created), "resourceVersion", created.GetResourceVersion())
+
+	// Deleted below, unless the program panics or gets interrupted midway.
+	lifecycle.Track(ctx, "ConfigMap", created, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(namespace).Delete(ctx, created.GetName(), metav1.DeleteOptions{})
+	})
 
 	if !reflect.DeepEqual(created.Data, desired.Data) {
 		panic("Created ConfigMap has unexpected data")
@@ -59,7 +106,7 @@ func main() {
 		CoreV1().
 		ConfigMaps(namespace).
 		Get(
-			context.Background(),
+			ctx,
 			created.GetName(),
 			metav1.GetOptions{},
 		)
@@ -67,7 +114,7 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Read ConfigMap %s/%s\n", namespace, read.GetName())
+	logger.Info("Read ConfigMap", "configMap", klog.KObj(read), "resourceVersion", read.GetResourceVersion())
 
 	if !reflect.DeepEqual(read.Data, desired.Data) {
 		panic("Read ConfigMap has unexpected data")
@@ -79,7 +126,7 @@ func main() {
 		CoreV1().
 		ConfigMaps(namespace).
 		Update(
-			context.Background(),
+			ctx,
 			read,
 			metav1.UpdateOptions{},
 		)
@@ -87,24 +134,232 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Updated ConfigMap %s/%s\n", namespace, updated.GetName())
+	logger.Info("Updated ConfigMap", "configMap", klog.KObj(updated), "resourceVersion", updated.GetResourceVersion())
 
 	if !reflect.DeepEqual(updated.Data, read.Data) {
 		panic("Updated ConfigMap has unexpected data")
 	}
 
-	// Delete
+	// Delete with preconditions - the API server refuses to delete the object
+	// (409 Conflict) unless it still has the given UID and/or resourceVersion.
+	// The resourceVersion of `created` is stale since the update above.
+	err = client.
+		CoreV1().
+		ConfigMaps(namespace).
+		Delete(
+			ctx,
+			created.GetName(),
+			metav1.DeleteOptions{
+				Preconditions: metav1.NewRVDeletionPrecondition(created.GetResourceVersion()).Preconditions,
+			},
+		)
+	if !errors.IsConflict(err) {
+		panic(fmt.Sprintf("Delete with a stale resourceVersion precondition: expected Conflict, got %v", err))
+	}
+
+	logger.Info("Delete with a stale resourceVersion refused", "configMap", klog.KObj(created), "err", err.Error())
+
+	// A UID precondition guards against deleting a different object with the
+	// same name (e.g., deleted and re-created by someone else in between).
 	err = client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Delete(
-			context.Background(),
+			ctx,
 			created.GetName(),
+			*metav1.NewPreconditionDeleteOptions("00000000-0000-0000-0000-000000000000"),
+		)
+	if !errors.IsConflict(err) {
+		panic(fmt.Sprintf("Delete with a foreign UID precondition: expected Conflict, got %v", err))
+	}
+
+	logger.Info("Delete with a foreign UID refused", "configMap", klog.KObj(created), "err", err.Error())
+
+	// Dry run - the API server does all the checks (preconditions included),
+	// but doesn't persist the result.
+	err = client.
+		CoreV1().
+		ConfigMaps(namespace).
+		Delete(
+			ctx,
+			created.GetName(),
+			metav1.DeleteOptions{
+				DryRun:        []string{metav1.DryRunAll},
+				Preconditions: metav1.NewUIDPreconditions(string(updated.GetUID())),
+			},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if _, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, created.GetName(), metav1.GetOptions{}); err != nil {
+		panic(fmt.Sprintf("ConfigMap is gone after a dry-run delete: %v", err))
+	}
+
+	logger.Info("Dry-run deleted ConfigMap", "configMap", klog.KObj(created))
+
+	// Delete for real - with the up-to-date preconditions and a grace period.
+	// The grace period is a no-op for a ConfigMap: only Pods support
+	// graceful deletion (the kubelet gets the time to stop the containers),
+	// other objects are deleted right away regardless of GracePeriodSeconds.
+	gracePeriod := int64(30)
+	err = client.
+		CoreV1().
+		ConfigMaps(namespace).
+		Delete(
+			ctx,
+			created.GetName(),
+			metav1.DeleteOptions{
+				GracePeriodSeconds: &gracePeriod,
+				Preconditions: &metav1.Preconditions{
+					UID:             &updated.UID,
+					ResourceVersion: &updated.ResourceVersion,
+				},
+			},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if _, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, created.GetName(), metav1.GetOptions{}); !errors.IsNotFound(err) {
+		panic(fmt.Sprintf("ConfigMap survived a delete with a grace period: expected NotFound, got %v", err))
+	}
+
+	logger.Info("Deleted ConfigMap", "configMap", klog.KObj(created), "gracePeriodSeconds", gracePeriod)
+
+	// Propagation policies decide what happens to the dependents - the objects
+	// referring to the deleted one in their ownerReferences:
+	//   - Background: the object is deleted right away, the garbage collector
+	//     deletes the dependents afterwards.
+	//   - Foreground: the object stays (with deletionTimestamp set) until the
+	//     garbage collector deletes the dependents, then goes away.
+	//   - Orphan: the garbage collector removes the ownerReferences from the
+	//     dependents, then deletes the object.
+	// These ConfigMaps have no dependents, so the policies are no-ops here -
+	// the loop only shows how to pass them. With the last two, the ConfigMap
+	// may still linger for a moment, waiting for the garbage collector. See
+	// owner-references for an owner with dependents, where each policy makes
+	// a difference.
+	for _, policy := range []metav1.DeletionPropagation{
+		metav1.DeletePropagationBackground,
+		metav1.DeletePropagationForeground,
+		metav1.DeletePropagationOrphan,
+	} {
+		cm := createConfigMap(ctx, client, namespace, nil)
+
+		err := client.
+			CoreV1().
+			ConfigMaps(namespace).
+			Delete(
+				ctx,
+				cm.GetName(),
+				metav1.DeleteOptions{PropagationPolicy: &policy},
+			)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		waitForDeletion(ctx, client, cm)
+
+		logger.Info("Deleted ConfigMap", "configMap", klog.KObj(cm), "propagationPolicy", policy)
+	}
+
+	// Delete collection - all the objects matching the label (and/or field)
+	// selector in one request. The object labeled `keep` must survive.
+	batch := "crud-typed-simple-" + rand.String(6)
+	for _, keep := range []bool{false, false, true} {
+		labels := map[string]string{"batch": batch}
+		if keep {
+			labels["keep"] = "true"
+		}
+		createConfigMap(ctx, client, namespace, labels)
+	}
+
+	err = client.
+		CoreV1().
+		ConfigMaps(namespace).
+		DeleteCollection(
+			ctx,
+			metav1.DeleteOptions{},
+			metav1.ListOptions{LabelSelector: "batch=" + batch + ",!keep"},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	left, err := client.
+		CoreV1().
+		ConfigMaps(namespace).
+		List(
+			ctx,
+			metav1.ListOptions{LabelSelector: "batch=" + batch},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+	if len(left.Items) != 1 || left.Items[0].Labels["keep"] != "true" {
+		panic(fmt.Sprintf("Expected only the kept ConfigMap to survive DeleteCollection, found %d", len(left.Items)))
+	}
+
+	logger.Info("Deleted ConfigMap collection", "labelSelector", "batch="+batch+",!keep")
+
+	// Clean up the survivor.
+	err = client.
+		CoreV1().
+		ConfigMaps(namespace).
+		Delete(
+			ctx,
+			left.Items[0].GetName(),
 			metav1.DeleteOptions{},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Deleted ConfigMap %s/%s\n", namespace, created.GetName())
+	logger.Info("Deleted ConfigMap", "configMap", klog.KObj(&left.Items[0]))
+}
+
+func createConfigMap(ctx context.Context, client kubernetes.Interface, namespace string, labels map[string]string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
+	cm.Namespace = namespace
+	cm.GenerateName = "crud-typed-simple-"
+	cm.SetLabels(map[string]string{"example": "crud-typed-simple"})
+	for k, v := range labels {
+		cm.Labels[k] = v
+	}
+
+	cm, err := client.
+		CoreV1().
+		ConfigMaps(namespace).
+		Create(
+			ctx,
+			cm,
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(namespace).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	})
+	return cm
+}
+
+// waitForDeletion polls until the ConfigMap is gone. With the Foreground
+// and Orphan propagation policies, the API server only marks it for
+// deletion and leaves the rest to the garbage collector.
+func waitForDeletion(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap) {
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true, func(ctx context.Context) (bool, error) {
+		_, err := client.CoreV1().ConfigMaps(cm.GetNamespace()).Get(ctx, cm.GetName(), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		panic(err.Error())
+	}
 }
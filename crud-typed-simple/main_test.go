@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/middleware"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRun(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	run(context.Background(), client, "default")
+
+	var verbs []string
+	var deletes []metav1.DeleteOptions
+	for _, action := range client.Actions() {
+		verbs = append(verbs, action.GetVerb())
+		if action, ok := action.(k8stesting.DeleteActionImpl); ok {
+			deletes = append(deletes, action.DeleteOptions)
+		}
+	}
+
+	want := []string{
+		"create", "get", "update",
+		// Stale resourceVersion, foreign UID, dry run (+ get), the real one (+ get).
+		"delete", "delete", "delete", "get", "delete", "get",
+		// A create, delete, and get per propagation policy.
+		"create", "delete", "get",
+		"create", "delete", "get",
+		"create", "delete", "get",
+		// The collection, the check, and the survivor.
+		"create", "create", "create", "delete-collection", "list", "delete",
+	}
+	if !reflect.DeepEqual(verbs, want) {
+		t.Fatalf("unexpected API calls:\ngot  %v\nwant %v", verbs, want)
+	}
+
+	if p := deletes[0].Preconditions; p == nil || p.ResourceVersion == nil {
+		t.Errorf("expected a resourceVersion precondition, got %+v", deletes[0])
+	}
+	if p := deletes[1].Preconditions; p == nil || p.UID == nil {
+		t.Errorf("expected a UID precondition, got %+v", deletes[1])
+	}
+	if !reflect.DeepEqual(deletes[2].DryRun, []string{metav1.DryRunAll}) {
+		t.Errorf("expected a dry-run delete, got %+v", deletes[2])
+	}
+	if g := deletes[3].GracePeriodSeconds; g == nil || *g != 30 {
+		t.Errorf("expected a 30s grace period, got %+v", deletes[3])
+	}
+
+	var policies []metav1.DeletionPropagation
+	for _, opts := range deletes[4:7] {
+		if opts.PropagationPolicy == nil {
+			t.Fatalf("expected a propagation policy, got %+v", opts)
+		}
+		policies = append(policies, *opts.PropagationPolicy)
+	}
+	wantPolicies := []metav1.DeletionPropagation{
+		metav1.DeletePropagationBackground,
+		metav1.DeletePropagationForeground,
+		metav1.DeletePropagationOrphan,
+	}
+	if !reflect.DeepEqual(policies, wantPolicies) {
+		t.Errorf("unexpected propagation policies: got %v, want %v", policies, wantPolicies)
+	}
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected all the ConfigMaps to be deleted, found %d ConfigMaps", len(list.Items))
+	}
+}
+
+func TestRunTransport(t *testing.T) {
+	// The fake clientsets have no transport to wrap - the requests go to
+	// the in-process API server instead.
+	server := fakeapiserver.NewServer()
+	defer server.Close()
+
+	config := server.RESTConfig()
+	config.QPS = -1
+	metrics := wrapTransport(config, false)
+
+	run(context.Background(), kubernetes.NewForConfigOrDie(config), "default")
+
+	type counts struct {
+		requests int
+		statuses map[int]int
+	}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	// The gets after the deletes find nothing - the in-process API server
+	// deletes the ConfigMaps at once, whatever the propagation policy.
+	want := map[string]counts{
+		"create":           {7, map[int]int{201: 7}},
+		"get":              {6, map[int]int{200: 2, 404: 4}},
+		"update":           {1, map[int]int{200: 1}},
+		"delete":           {8, map[int]int{200: 6, 409: 2}},
+		"deletecollection": {1, map[int]int{200: 1}},
+		"list":             {1, map[int]int{200: 1}},
+	}
+
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != len(want) {
+		t.Errorf("expected %d kinds of requests, got %v", len(want), snapshot)
+	}
+	for verb, w := range want {
+		s := snapshot[middleware.Key{Verb: verb, Resource: configMaps}]
+		if s.Requests != w.requests || !reflect.DeepEqual(s.Statuses, w.statuses) {
+			t.Errorf("%s: expected %d requests with statuses %v, got %d with %v", verb, w.requests, w.statuses, s.Requests, s.Statuses)
+		}
+	}
+}
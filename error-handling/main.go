@@ -2,41 +2,47 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path"
+	"flag"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("error-handling", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "error-handling"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	namespace := "default"
+	run(ctx, client, "default")
+}
+
+func run(ctx context.Context, client kubernetes.Interface, namespace string) {
+	logger := klog.FromContext(ctx)
 
 	// ERR_NOT_FOUND
-	_, err = client.
+	_, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Get(
-			context.Background(),
+			ctx,
 			"this_name_definitely_does_not_exist",
 			metav1.GetOptions{},
 		)
@@ -49,21 +55,27 @@ func main() {
 
 	desired := corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
 	desired.Namespace = namespace
-	desired.GenerateName = "crud-typed-simple-"
+	desired.GenerateName = "error-handling-"
+	desired.SetLabels(map[string]string{"example": "error-handling"})
 
 	// Create
 	created, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			&desired,
 			metav1.CreateOptions{},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
-	fmt.Printf("Created ConfigMap %s/%s\n", namespace, created.GetName())
+	logger.Info("Created ConfigMap", "configMap", klog.KObj(created), "resourceVersion", created.GetResourceVersion())
+
+	// The program doesn't delete the ConfigMap itself.
+	lifecycle.Track(ctx, "ConfigMap", created, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(namespace).Delete(ctx, created.GetName(), metav1.DeleteOptions{})
+	})
 
 	// ERR_ALREADY_EXISTS
 	duplicate := corev1.ConfigMap{}
@@ -73,7 +85,7 @@ func main() {
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			&duplicate,
 			metav1.CreateOptions{},
 		)
@@ -90,14 +102,14 @@ func main() {
 		CoreV1().
 		ConfigMaps(namespace).
 		Update(
-			context.Background(),
+			ctx,
 			created,
 			metav1.UpdateOptions{},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
-	fmt.Printf("Updated ConfigMap %s/%s\n", namespace, updated.GetName())
+	logger.Info("Updated ConfigMap", "configMap", klog.KObj(updated), "resourceVersion", updated.GetResourceVersion())
 
 	// ERR_CONFLICT
 	created.Data["baz"] = "def"
@@ -105,7 +117,7 @@ func main() {
 		CoreV1().
 		ConfigMaps(namespace).
 		Update(
-			context.Background(),
+			ctx,
 			created,
 			metav1.UpdateOptions{},
 		)
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRun(t *testing.T) {
+	// The conflict part of the program relies on the resourceVersion
+	// emulation - the plain fake clientset would accept the stale update.
+	client := fakeclient.NewClientset()
+
+	lc := lifecycle.New("error-handling")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	run(ctx, client, "default")
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected exactly one ConfigMap, found %d", len(list.Items))
+	}
+
+	// The conflicting update must not have made it to the storage.
+	want := map[string]string{"foo": "bar", "qux": "abc"}
+	if got := list.Items[0].Data; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected ConfigMap data: got %v, want %v", got, want)
+	}
+
+	lc.Finish()
+
+	list, err = client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMap to be cleaned up, found %d ConfigMaps", len(list.Items))
+	}
+}
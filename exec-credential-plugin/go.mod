@@ -0,0 +1,8 @@
+module github.com/iximiuz/client-go-examples/exec-credential-plugin
+
+go 1.22.10
+
+require (
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+)
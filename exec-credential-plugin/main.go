@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// go run main.go
+// go run main.go -plugin /path/to/prebuilt/plugin
+
+const tokenTTL = 2 * time.Second
+
+func main() {
+	pluginPath := flag.String("plugin", "", "path to the plugin binary (built from ./plugin if empty)")
+	flag.Parse()
+
+	tmpDir, err := os.MkdirTemp("", "exec-credential-plugin-")
+	if err != nil {
+		panic(err.Error())
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if *pluginPath == "" {
+		if *pluginPath, err = buildPlugin(tmpDir); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	// A stand-in for a cloud provider's token endpoint...
+	issuer := newTokenIssuer(tokenTTL)
+	defer issuer.Close()
+
+	// ...and for an API server that only accepts the issued tokens.
+	apiServer := newAPIServer(issuer)
+	defer apiServer.Close()
+
+	cacheFile := filepath.Join(tmpDir, "token-cache.json")
+
+	// 1. The plugin is run once, and client-go keeps the credential in memory
+	//    until the plugin-provided expirationTimestamp.
+	fmt.Println("# Token caching and rotation")
+	client := newClient(apiServer, *pluginPath, api.NeverExecInteractiveMode, []api.ExecEnvVar{
+		{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+		{Name: "TOKEN_CACHE_FILE", Value: cacheFile},
+	})
+	for i := 0; i < 3; i++ {
+		serverVersion(client)
+	}
+	if issuer.Issued() != 1 {
+		panic(fmt.Sprintf("expected 1 issued token, got %d", issuer.Issued()))
+	}
+
+	//    Once the token expires, client-go runs the plugin again.
+	time.Sleep(tokenTTL + 500*time.Millisecond)
+	serverVersion(client)
+	if issuer.Issued() != 2 {
+		panic(fmt.Sprintf("expected 2 issued tokens after expiration, got %d", issuer.Issued()))
+	}
+	if seen := apiServer.SeenTokens(); len(seen) != 2 {
+		panic(fmt.Sprintf("expected the API server to see 2 distinct tokens, got %v", seen))
+	}
+	fmt.Printf("Token rotated, API server saw %v\n\n", apiServer.SeenTokens())
+
+	// 2. A different exec config (hence, a new authenticator - think of another
+	//    kubectl invocation) runs the plugin again, but the plugin's own file
+	//    cache saves a trip to the issuer.
+	fmt.Println("# Plugin-side token cache")
+	client = newClient(apiServer, *pluginPath, api.NeverExecInteractiveMode, []api.ExecEnvVar{
+		{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+		{Name: "TOKEN_CACHE_FILE", Value: cacheFile},
+		{Name: "INVOCATION", Value: "second"},
+	})
+	serverVersion(client)
+	if issuer.Issued() != 2 {
+		panic(fmt.Sprintf("expected the cached token to be reused, but %d tokens were issued", issuer.Issued()))
+	}
+	fmt.Println("Reused the token from the plugin's cache")
+	fmt.Println()
+
+	// 3. interactiveMode decides whether the plugin may talk to the user.
+	//    The plugin pretends a login is needed (no cached token), so it
+	//    can't succeed non-interactively.
+	fmt.Println("# interactiveMode")
+	for _, mode := range []api.ExecInteractiveMode{
+		api.NeverExecInteractiveMode,
+		api.IfAvailableExecInteractiveMode,
+		api.AlwaysExecInteractiveMode,
+	} {
+		client := newClient(apiServer, *pluginPath, mode, []api.ExecEnvVar{
+			{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+			{Name: "TOKEN_CACHE_FILE", Value: filepath.Join(tmpDir, "login-"+string(mode)+".json")},
+			{Name: "REQUIRE_LOGIN", Value: "1"},
+		})
+
+		_, err := client.ServerVersion()
+		if mode == api.NeverExecInteractiveMode && err == nil {
+			panic("plugin must not be able to log in with interactiveMode: Never")
+		}
+		if err != nil {
+			fmt.Printf("interactiveMode: %s -> %v\n", mode, firstLine(err))
+		} else {
+			fmt.Printf("interactiveMode: %s -> logged in\n", mode)
+		}
+	}
+}
+
+// buildPlugin builds the plugin into dir. The package is referred to by its
+// import path, so it doesn't matter which directory of the module (or the
+// workspace) the program is run from.
+func buildPlugin(dir string) (string, error) {
+	path := filepath.Join(dir, "exec-plugin")
+	build := exec.Command("go", "build", "-o", path, "github.com/iximiuz/client-go-examples/exec-credential-plugin/plugin")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		return "", fmt.Errorf("unable to build the plugin: %w", err)
+	}
+	return path, nil
+}
+
+func newClient(
+	apiServer *apiServer,
+	pluginPath string,
+	interactiveMode api.ExecInteractiveMode,
+	env []api.ExecEnvVar,
+) *discovery.DiscoveryClient {
+	// The same thing as the following kubeconfig snippet:
+	//
+	//   users:
+	//   - name: exec-user
+	//     user:
+	//       exec:
+	//         apiVersion: client.authentication.k8s.io/v1
+	//         command: /path/to/exec-plugin
+	//         env:
+	//         - name: TOKEN_ISSUER_URL
+	//           value: http://127.0.0.1:12345
+	//         interactiveMode: Never
+	kubeconfig := api.NewConfig()
+	kubeconfig.Clusters["stand-in"] = &api.Cluster{
+		Server:                   apiServer.URL,
+		CertificateAuthorityData: apiServer.CAData(),
+	}
+	kubeconfig.AuthInfos["exec-user"] = &api.AuthInfo{
+		Exec: &api.ExecConfig{
+			APIVersion:      "client.authentication.k8s.io/v1",
+			Command:         pluginPath,
+			Env:             env,
+			InteractiveMode: interactiveMode,
+		},
+	}
+	kubeconfig.Contexts["stand-in"] = &api.Context{Cluster: "stand-in", AuthInfo: "exec-user"}
+	kubeconfig.CurrentContext = "stand-in"
+
+	config, err := clientcmd.NewDefaultClientConfig(*kubeconfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return discovery.NewDiscoveryClientForConfigOrDie(config)
+}
+
+func serverVersion(client *discovery.DiscoveryClient) {
+	ver, err := client.ServerVersion()
+	if err != nil {
+		panic(err.Error())
+	}
+	fmt.Printf("Server version %s\n", ver.GitVersion)
+}
+
+type tokenIssuer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]time.Time
+}
+
+func newTokenIssuer(ttl time.Duration) *tokenIssuer {
+	issuer := &tokenIssuer{ttl: ttl, tokens: map[string]time.Time{}}
+	issuer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		token := fmt.Sprintf("token-%d", len(issuer.tokens)+1)
+		expiresAt := time.Now().Add(issuer.ttl)
+		issuer.tokens[token] = expiresAt
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":     token,
+			"expiresAt": expiresAt,
+		})
+	}))
+	return issuer
+}
+
+func (i *tokenIssuer) Valid(token string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	expiresAt, ok := i.tokens[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (i *tokenIssuer) Issued() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return len(i.tokens)
+}
+
+type apiServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	seen []string
+}
+
+func newAPIServer(issuer *tokenIssuer) *apiServer {
+	s := &apiServer{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !issuer.Valid(token) {
+			// client-go reacts to 401 by running the plugin once again.
+			http.Error(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`, http.StatusUnauthorized)
+			return
+		}
+		s.see(token)
+
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(version.Info{GitVersion: "v0.0.0-stand-in"})
+	}))
+	return s
+}
+
+func (s *apiServer) see(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.seen {
+		if t == token {
+			return
+		}
+	}
+	s.seen = append(s.seen, token)
+}
+
+func (s *apiServer) SeenTokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.seen...)
+}
+
+func (s *apiServer) CAData() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+func firstLine(err error) string {
+	return strings.SplitN(err.Error(), "\n", 2)[0]
+}
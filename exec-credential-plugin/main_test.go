@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// plugin builds the plugin once per test into the test's temp dir.
+func plugin(t *testing.T) string {
+	t.Helper()
+
+	path, err := buildPlugin(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRotation(t *testing.T) {
+	pluginPath := plugin(t)
+
+	const ttl = time.Second
+	issuer := newTokenIssuer(ttl)
+	defer issuer.Close()
+	apiServer := newAPIServer(issuer)
+	defer apiServer.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "token-cache.json")
+
+	client := newClient(apiServer, pluginPath, api.NeverExecInteractiveMode, []api.ExecEnvVar{
+		{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+		{Name: "TOKEN_CACHE_FILE", Value: cacheFile},
+	})
+
+	// client-go keeps the credential in memory until it expires.
+	for i := 0; i < 3; i++ {
+		if _, err := client.ServerVersion(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if issued := issuer.Issued(); issued != 1 {
+		t.Fatalf("expected 1 issued token before the expiration, got %d", issued)
+	}
+
+	time.Sleep(ttl + 500*time.Millisecond)
+	if _, err := client.ServerVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if issued := issuer.Issued(); issued != 2 {
+		t.Fatalf("expected 2 issued tokens after the expiration, got %d", issued)
+	}
+	if seen := apiServer.SeenTokens(); len(seen) != 2 || seen[0] == seen[1] {
+		t.Errorf("expected the API server to see 2 distinct tokens, got %v", seen)
+	}
+
+	// A new authenticator runs the plugin again, which serves the token
+	// from its own cache.
+	client = newClient(apiServer, pluginPath, api.NeverExecInteractiveMode, []api.ExecEnvVar{
+		{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+		{Name: "TOKEN_CACHE_FILE", Value: cacheFile},
+		{Name: "INVOCATION", Value: "second"},
+	})
+	if _, err := client.ServerVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if issued := issuer.Issued(); issued != 2 {
+		t.Errorf("expected the cached token to be reused, but %d tokens were issued", issued)
+	}
+}
+
+func TestInteractiveMode(t *testing.T) {
+	pluginPath := plugin(t)
+
+	issuer := newTokenIssuer(time.Minute)
+	defer issuer.Close()
+	apiServer := newAPIServer(issuer)
+	defer apiServer.Close()
+
+	// client-go checks its own stdin - make sure it's not a terminal,
+	// whatever go test is run from.
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdin := os.Stdin
+	os.Stdin = devNull
+	defer func() { os.Stdin = stdin }()
+
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		mode   api.ExecInteractiveMode
+		err    string
+		stderr string
+	}{
+		// The plugin is run with spec.interactive=false and can't log in.
+		{api.NeverExecInteractiveMode, "failed with exit code 1", "interactive login required"},
+		// Same, since there is no terminal to be interactive with.
+		{api.IfAvailableExecInteractiveMode, "failed with exit code 1", "interactive login required"},
+		// client-go refuses to run the plugin at all.
+		{api.AlwaysExecInteractiveMode, "cannot support interactive mode: standard input is not a terminal", ""},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			// The plugin's stderr goes to client-go's, as it was when the
+			// client was created.
+			stderrFile := filepath.Join(dir, "stderr-"+string(tc.mode))
+			stderr, err := os.Create(stderrFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stderr.Close()
+			defaultStderr := os.Stderr
+			os.Stderr = stderr
+			defer func() { os.Stderr = defaultStderr }()
+
+			client := newClient(apiServer, pluginPath, tc.mode, []api.ExecEnvVar{
+				{Name: "TOKEN_ISSUER_URL", Value: issuer.URL},
+				{Name: "TOKEN_CACHE_FILE", Value: filepath.Join(dir, "login-"+string(tc.mode)+".json")},
+				{Name: "REQUIRE_LOGIN", Value: "1"},
+			})
+
+			_, err = client.ServerVersion()
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+			}
+
+			output, err := os.ReadFile(stderrFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.stderr == "" && len(output) > 0 {
+				t.Errorf("expected the plugin not to be run, got %q", output)
+			}
+			if !strings.Contains(string(output), tc.stderr) {
+				t.Errorf("expected %q from the plugin, got %q", tc.stderr, output)
+			}
+		})
+	}
+
+	if issued := issuer.Issued(); issued != 0 {
+		t.Errorf("expected no tokens issued without a login, got %d", issued)
+	}
+}
@@ -0,0 +1,138 @@
+// A client.authentication.k8s.io/v1 exec credential plugin.
+//
+// client-go runs it whenever it needs a (new) credential and passes the
+// request in the KUBERNETES_EXEC_INFO env var. The plugin answers with
+// an ExecCredential JSON on stdout.
+//
+// Tokens are obtained from a token issuer (TOKEN_ISSUER_URL) and cached
+// in TOKEN_CACHE_FILE until they expire, much like cloud provider
+// plugins do. With REQUIRE_LOGIN=1 the issuer is pretended to need an
+// interactive login, so the plugin fails unless client-go says it's
+// allowed to talk to the user.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+)
+
+type cachedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func main() {
+	if err := run(); err != nil {
+		// Whatever goes to stderr is shown to the user by client-go.
+		fmt.Fprintf(os.Stderr, "exec-plugin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var req clientauthv1.ExecCredential
+	if err := json.Unmarshal([]byte(os.Getenv("KUBERNETES_EXEC_INFO")), &req); err != nil {
+		return fmt.Errorf("unable to parse KUBERNETES_EXEC_INFO: %w", err)
+	}
+	if req.APIVersion != clientauthv1.SchemeGroupVersion.String() {
+		return fmt.Errorf("unsupported apiVersion %q", req.APIVersion)
+	}
+
+	cacheFile := os.Getenv("TOKEN_CACHE_FILE")
+
+	token, err := readCache(cacheFile)
+	if err != nil {
+		return err
+	}
+
+	if token == nil || time.Now().After(token.ExpiresAt) {
+		if os.Getenv("REQUIRE_LOGIN") == "1" {
+			// spec.interactive is true only if the kubeconfig's interactiveMode
+			// allows it AND stdin is a terminal (or it's Always).
+			if !req.Spec.Interactive {
+				return fmt.Errorf("interactive login required, but client-go runs the plugin non-interactively")
+			}
+			fmt.Fprint(os.Stderr, "Log in to the token issuer and press Enter... ")
+			if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+				return err
+			}
+		}
+
+		if token, err = issueToken(os.Getenv("TOKEN_ISSUER_URL")); err != nil {
+			return err
+		}
+		if err := writeCache(cacheFile, token); err != nil {
+			return err
+		}
+	}
+
+	// The expiration timestamp makes client-go run the plugin again once
+	// the token expires. Without it, the token is used until a 401.
+	expiresAt := metav1.NewTime(token.ExpiresAt)
+	resp := clientauthv1.ExecCredential{
+		TypeMeta: req.TypeMeta,
+		Status: &clientauthv1.ExecCredentialStatus{
+			Token:               token.Token,
+			ExpirationTimestamp: &expiresAt,
+		},
+	}
+	return json.NewEncoder(os.Stdout).Encode(resp)
+}
+
+func issueToken(issuerURL string) (*cachedToken, error) {
+	resp, err := http.Post(issuerURL, "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token issuer responded with %s", resp.Status)
+	}
+
+	var token cachedToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func readCache(filename string) (*cachedToken, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token cachedToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		// A corrupted cache is not fatal - just get a new token.
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func writeCache(filename string, token *cachedToken) error {
+	if filename == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0600)
+}
@@ -0,0 +1,46 @@
+// Runs the fake API server until interrupted, so that the example
+// programs can be pointed at it:
+//
+//	go run ./cmd/fakeapiserver -kubeconfig /tmp/fakeapiserver.kubeconfig
+//	cd ../../crud-typed-simple && go run main.go --kubeconfig /tmp/fakeapiserver.kubeconfig
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:0", "address to listen on")
+	kubeconfig := flag.String("kubeconfig", "fakeapiserver.kubeconfig", "where to write the kubeconfig for the server")
+	flag.Parse()
+
+	server := fakeapiserver.New()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		panic(err.Error())
+	}
+	server.Listener.Close()
+	server.Listener = listener
+
+	server.StartTLS()
+	defer server.Close()
+
+	if err := server.WriteKubeconfig(*kubeconfig); err != nil {
+		panic(err.Error())
+	}
+
+	fmt.Printf("Serving on %s\n", server.URL)
+	fmt.Printf("Use it with: --kubeconfig %s (or KUBECONFIG=%s)\n", *kubeconfig, *kubeconfig)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+}
@@ -0,0 +1,206 @@
+package fakeapiserver
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// deleteObject deletes the object with the propagation policy from opts
+// and does the garbage collector's job right away - by the time it returns,
+// the dependents are deleted (or orphaned). The watchers see the same
+// events, in the same order, as from the real API server:
+//
+//   - Background: the owner is deleted, then its dependents.
+//   - Foreground: the owner is marked with deletionTimestamp and the
+//     foregroundDeletion finalizer, the dependents blocking its deletion
+//     are deleted, then the owner, then the rest of the dependents.
+//   - Orphan: the owner is marked with deletionTimestamp and the orphan
+//     finalizer, the dependents lose their references to it, then the
+//     owner is deleted.
+//
+// The dependents having other owners only lose the references to the
+// deleted one.
+func (s *Server) deleteObject(res *resource, ns, name string, opts *metav1.DeleteOptions, dryRun bool) (object, error) {
+	policy := metav1.DeletePropagationBackground
+	switch {
+	case opts.PropagationPolicy != nil:
+		policy = *opts.PropagationPolicy
+	case opts.OrphanDependents != nil && *opts.OrphanDependents:
+		policy = metav1.DeletePropagationOrphan
+	}
+
+	var finalizers []string
+	switch policy {
+	case metav1.DeletePropagationForeground:
+		finalizers = []string{metav1.FinalizerDeleteDependents}
+	case metav1.DeletePropagationOrphan:
+		finalizers = []string{metav1.FinalizerOrphanDependents}
+	}
+
+	owner, err := s.store.delete(res, ns, name, opts.Preconditions, dryRun, finalizers...)
+	if err != nil || dryRun {
+		return owner, err
+	}
+
+	dependents := s.dependents(owner)
+	switch policy {
+	case metav1.DeletePropagationForeground:
+		var rest []dependent
+		for _, d := range dependents {
+			if d.blocks(owner) {
+				s.collect(d, owner, metav1.DeletePropagationForeground)
+			} else {
+				rest = append(rest, d)
+			}
+		}
+		deleted, err := s.removeFinalizer(res, owner, metav1.FinalizerDeleteDependents)
+		for _, d := range rest {
+			s.collect(d, owner, metav1.DeletePropagationBackground)
+		}
+		return deleted, err
+
+	case metav1.DeletePropagationOrphan:
+		for _, d := range dependents {
+			s.removeOwnerReference(d, owner)
+		}
+		return s.removeFinalizer(res, owner, metav1.FinalizerOrphanDependents)
+
+	default:
+		for _, d := range dependents {
+			s.collect(d, owner, metav1.DeletePropagationBackground)
+		}
+		return owner, nil
+	}
+}
+
+type dependent struct {
+	res *resource
+	obj object
+}
+
+// blocks tells if the foreground deletion of the owner waits for the
+// dependent to be deleted.
+func (d dependent) blocks(owner object) bool {
+	for _, ref := range d.obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return ref.BlockOwnerDeletion != nil && *ref.BlockOwnerDeletion
+		}
+	}
+	return false
+}
+
+// dependents returns the objects referring to the owner in their
+// ownerReferences. The dependents of a namespaced owner can only be in
+// its namespace.
+func (s *Server) dependents(owner object) []dependent {
+	var list []dependent
+	for _, name := range sortedKeys(resources) {
+		res := resources[name]
+		if owner.GetNamespace() != "" && res.clusterScoped {
+			continue
+		}
+
+		items, _ := s.store.list(res, owner.GetNamespace(), selector{labels: labels.Everything(), fields: fields.Everything()})
+		for _, item := range items {
+			if hasOwnerReference(item, owner.GetUID()) {
+				list = append(list, dependent{res: res, obj: item})
+			}
+		}
+	}
+	return list
+}
+
+// collect deletes the dependent of the deleted owner, unless the dependent
+// has other owners still around - then it only removes the reference.
+func (s *Server) collect(d dependent, owner object, policy metav1.DeletionPropagation) {
+	// Being deleted already (and maybe depending on the owner in a cycle).
+	if d.obj.GetDeletionTimestamp() != nil {
+		return
+	}
+
+	for _, ref := range d.obj.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() && s.ownerExists(d.obj.GetNamespace(), ref) {
+			s.removeOwnerReference(d, owner)
+			return
+		}
+	}
+
+	// Errors mean the dependent is gone already.
+	s.deleteObject(d.res, d.obj.GetNamespace(), d.obj.GetName(), &metav1.DeleteOptions{PropagationPolicy: &policy}, false)
+}
+
+// ownerExists tells if the referenced owner exists and isn't being deleted
+// in the foreground (waiting for its dependents to go first).
+func (s *Server) ownerExists(ns string, ref metav1.OwnerReference) bool {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false
+	}
+	var res *resource
+	for _, r := range resources {
+		if r.gv == gv && r.kind == ref.Kind {
+			res = r
+		}
+	}
+	if res == nil {
+		return false
+	}
+	if res.clusterScoped {
+		ns = ""
+	}
+
+	owner, err := s.store.get(res, ns, ref.Name)
+	if err != nil || owner.GetUID() != ref.UID {
+		return false
+	}
+	for _, f := range owner.GetFinalizers() {
+		if f == metav1.FinalizerDeleteDependents && owner.GetDeletionTimestamp() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) removeOwnerReference(d dependent, owner object) {
+	obj, err := s.store.get(d.res, d.obj.GetNamespace(), d.obj.GetName())
+	if err != nil {
+		return
+	}
+
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	s.store.update(d.res, obj, false)
+}
+
+func (s *Server) removeFinalizer(res *resource, obj object, finalizer string) (object, error) {
+	stored, err := s.store.get(res, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	var finalizers []string
+	for _, f := range stored.GetFinalizers() {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	stored.SetFinalizers(finalizers)
+	return s.store.update(res, stored, false)
+}
+
+func hasOwnerReference(obj object, uid types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package fakeapiserver
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// resource describes how a resource is served.
+type resource struct {
+	gv            schema.GroupVersion
+	name          string
+	kind          string
+	shortNames    []string
+	clusterScoped bool
+	new           func() object
+
+	// prepareForCreate sets whatever the server owns on a new object
+	// (e.g., Deployment's generation).
+	prepareForCreate func(obj object)
+
+	// prepareForUpdate copies from the stored object whatever updates of
+	// the main resource can't change (e.g., Pod's status).
+	prepareForUpdate func(stored, updated object)
+
+	// subresources return the stored object with only the part of the
+	// update the subresource is responsible for.
+	subresources map[string]func(stored, updated object) object
+
+	// handlers serve the subresources that aren't a part of the object.
+	handlers map[string]*handler
+}
+
+// handler serves a subresource of a kind of its own, like deployments/scale
+// or pods/eviction. The object it belongs to is passed in as stored.
+type handler struct {
+	gvk   schema.GroupVersionKind
+	verbs metav1.Verbs
+	serve func(s *Server, w http.ResponseWriter, r *http.Request, res *resource, stored object)
+}
+
+var resources = map[string]*resource{
+	"configmaps": {
+		gv:         corev1.SchemeGroupVersion,
+		name:       "configmaps",
+		kind:       "ConfigMap",
+		shortNames: []string{"cm"},
+		new:        func() object { return &corev1.ConfigMap{} },
+	},
+
+	"secrets": {
+		gv:   corev1.SchemeGroupVersion,
+		name: "secrets",
+		kind: "Secret",
+		new:  func() object { return &corev1.Secret{} },
+	},
+
+	"pods": {
+		gv:         corev1.SchemeGroupVersion,
+		name:       "pods",
+		kind:       "Pod",
+		shortNames: []string{"po"},
+		new:        func() object { return &corev1.Pod{} },
+		prepareForUpdate: func(stored, updated object) {
+			updated.(*corev1.Pod).Status = stored.(*corev1.Pod).Status
+		},
+		handlers: map[string]*handler{
+			"binding":  bindingHandler,
+			"eviction": evictionHandler,
+		},
+		subresources: map[string]func(stored, updated object) object{
+			"status": func(stored, updated object) object {
+				pod := stored.DeepCopyObject().(*corev1.Pod)
+				pod.Status = updated.(*corev1.Pod).Status
+				return pod
+			},
+			"ephemeralcontainers": func(stored, updated object) object {
+				pod := stored.DeepCopyObject().(*corev1.Pod)
+				pod.Spec.EphemeralContainers = updated.(*corev1.Pod).Spec.EphemeralContainers
+				return pod
+			},
+		},
+	},
+
+	// Nothing ever rolls the Deployments out - there are no controllers.
+	// The generation is maintained like the real API server does, though.
+	"deployments": {
+		gv:         appsv1.SchemeGroupVersion,
+		name:       "deployments",
+		kind:       "Deployment",
+		shortNames: []string{"deploy"},
+		new:        func() object { return &appsv1.Deployment{} },
+		prepareForCreate: func(obj object) {
+			obj.SetGeneration(1)
+		},
+		prepareForUpdate: func(stored, updated object) {
+			updated.(*appsv1.Deployment).Status = stored.(*appsv1.Deployment).Status
+			updated.SetGeneration(deploymentGeneration(stored.(*appsv1.Deployment), updated.(*appsv1.Deployment)))
+		},
+		subresources: map[string]func(stored, updated object) object{
+			"status": func(stored, updated object) object {
+				deployment := stored.DeepCopyObject().(*appsv1.Deployment)
+				deployment.Status = updated.(*appsv1.Deployment).Status
+				return deployment
+			},
+		},
+		handlers: map[string]*handler{
+			"scale": scaleHandler,
+		},
+	},
+
+	"poddisruptionbudgets": podDisruptionBudgets,
+
+	// The server starts with a single Node (see New) to bind the Pods to.
+	"nodes": {
+		gv:            corev1.SchemeGroupVersion,
+		name:          "nodes",
+		kind:          "Node",
+		shortNames:    []string{"no"},
+		clusterScoped: true,
+		new:           func() object { return &corev1.Node{} },
+		prepareForUpdate: func(stored, updated object) {
+			updated.(*corev1.Node).Status = stored.(*corev1.Node).Status
+		},
+		subresources: map[string]func(stored, updated object) object{
+			"status": func(stored, updated object) object {
+				node := stored.DeepCopyObject().(*corev1.Node)
+				node.Status = updated.(*corev1.Node).Status
+				return node
+			},
+		},
+	},
+}
+
+// lookup returns the resource served under the given group version.
+// podDisruptionBudgets is referenced by the pods/eviction handler, which
+// can't look it up in resources (that would be an initialization cycle).
+// The disruptions allowed are computed on eviction - there is no
+// disruption controller filling in the status.
+var podDisruptionBudgets = &resource{
+	gv:         policyv1.SchemeGroupVersion,
+	name:       "poddisruptionbudgets",
+	kind:       "PodDisruptionBudget",
+	shortNames: []string{"pdb"},
+	new:        func() object { return &policyv1.PodDisruptionBudget{} },
+	prepareForUpdate: func(stored, updated object) {
+		updated.(*policyv1.PodDisruptionBudget).Status = stored.(*policyv1.PodDisruptionBudget).Status
+	},
+}
+
+// deploymentGeneration returns the generation of the updated Deployment.
+// Changes to the spec (and the annotations, which are copied to the
+// ReplicaSets) bump it, status and other metadata updates don't.
+func deploymentGeneration(stored, updated *appsv1.Deployment) int64 {
+	if equality.Semantic.DeepEqual(stored.Spec, updated.Spec) &&
+		equality.Semantic.DeepEqual(stored.Annotations, updated.Annotations) {
+		return stored.Generation
+	}
+	return stored.Generation + 1
+}
+
+func lookup(gv schema.GroupVersion, name string) (*resource, bool) {
+	res, ok := resources[name]
+	if !ok || res.gv != gv {
+		return nil, false
+	}
+	return res, true
+}
+
+// groupVersions returns the served group versions, the core one first.
+func groupVersions() []schema.GroupVersion {
+	seen := map[string]schema.GroupVersion{}
+	for _, res := range resources {
+		seen[res.gv.String()] = res.gv
+	}
+
+	var list []schema.GroupVersion
+	for _, key := range sortedKeys(seen) {
+		list = append(list, seen[key])
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Group == "" && list[j].Group != "" })
+	return list
+}
+
+// apiResourceList is what discovery returns for the group version.
+func apiResourceList(gv schema.GroupVersion) metav1.APIResourceList {
+	list := metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+	}
+	for _, name := range sortedKeys(resources) {
+		if res := resources[name]; res.gv == gv {
+			list.APIResources = append(list.APIResources, res.apiResources()...)
+		}
+	}
+	return list
+}
+
+func (r *resource) apiResources() []metav1.APIResource {
+	list := []metav1.APIResource{{
+		Name:         r.name,
+		SingularName: strings.ToLower(r.kind),
+		Namespaced:   !r.clusterScoped,
+		Kind:         r.kind,
+		ShortNames:   r.shortNames,
+		Verbs:        metav1.Verbs{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"},
+	}}
+	for _, sub := range sortedKeys(r.subresources) {
+		list = append(list, metav1.APIResource{
+			Name:       r.name + "/" + sub,
+			Namespaced: !r.clusterScoped,
+			Kind:       r.kind,
+			Verbs:      metav1.Verbs{"get", "patch", "update"},
+		})
+	}
+	for _, sub := range sortedKeys(r.handlers) {
+		h := r.handlers[sub]
+		list = append(list, metav1.APIResource{
+			Name:       r.name + "/" + sub,
+			Namespaced: !r.clusterScoped,
+			Group:      h.gvk.Group,
+			Version:    h.gvk.Version,
+			Kind:       h.gvk.Kind,
+			Verbs:      h.verbs,
+		})
+	}
+	sort.Slice(list[1:], func(i, j int) bool { return list[1+i].Name < list[1+j].Name })
+	return list
+}
+
+func (r *resource) setTypeMeta(obj object) {
+	obj.GetObjectKind().SetGroupVersionKind(r.gv.WithKind(r.kind))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package fakeapiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeObject(w http.ResponseWriter, code int, res *resource, obj object) {
+	res.setTypeMeta(obj)
+	writeJSON(w, code, obj)
+}
+
+func writeList(w http.ResponseWriter, res *resource, items []object, rv int64) {
+	list := struct {
+		metav1.TypeMeta `json:",inline"`
+		Metadata        metav1.ListMeta `json:"metadata"`
+		Items           []object        `json:"items"`
+	}{
+		TypeMeta: metav1.TypeMeta{Kind: res.kind + "List", APIVersion: res.gv.String()},
+		Metadata: metav1.ListMeta{ResourceVersion: strconv.FormatInt(rv, 10)},
+		Items:    []object{},
+	}
+	for _, item := range items {
+		res.setTypeMeta(item)
+		list.Items = append(list.Items, item)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// writeError responds with a Status object - the only kind of error
+// response client-go turns into a typed error (errors.IsNotFound() etc.).
+func writeError(w http.ResponseWriter, err error) {
+	var status metav1.Status
+	if apiStatus, ok := err.(apierrors.APIStatus); ok {
+		status = apiStatus.Status()
+	} else {
+		status = apierrors.NewInternalError(err).Status()
+	}
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+
+	writeJSON(w, int(status.Code), status)
+}
+
+func notFound(r *http.Request) error {
+	return apierrors.NewGenericServerResponse(
+		http.StatusNotFound, r.Method, schema.GroupResource{}, "",
+		fmt.Sprintf("the server could not find the requested resource (%s %s)", r.Method, r.URL.Path),
+		0, false,
+	)
+}
+
+func methodNotSupported(r *http.Request) error {
+	return apierrors.NewMethodNotSupported(schema.GroupResource{}, r.Method)
+}
+
+func isWatch(query url.Values) bool {
+	watch := query.Get("watch")
+	return watch == "true" || watch == "1"
+}
+
+func isDryRun(query url.Values) bool {
+	return query.Get("dryRun") == metav1.DryRunAll
+}
+
+// isDryRunDelete covers deletes, which pass dryRun in the body.
+func isDryRunDelete(opts *metav1.DeleteOptions) bool {
+	for _, v := range opts.DryRun {
+		if v == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
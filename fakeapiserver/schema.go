@@ -0,0 +1,133 @@
+package fakeapiserver
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// models is a tiny excerpt of the OpenAPI spec of the real API server -
+// just enough for server-side apply to merge the lists the way it does
+// there. Everything not mentioned (x-kubernetes-preserve-unknown-fields)
+// is deduced from the objects: maps are merged key by key, other lists
+// are atomic.
+const models = `{
+  "io.k8s.api.core.v1.ConfigMap": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "ConfigMap"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+    }
+  },
+  "io.k8s.api.core.v1.Secret": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "Secret"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+    }
+  },
+  "io.k8s.api.core.v1.Pod": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "Pod"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+      "spec": {"$ref": "#/definitions/io.k8s.api.core.v1.PodSpec"}
+    }
+  },
+  "io.k8s.api.apps.v1.Deployment": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "apps", "version": "v1", "kind": "Deployment"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+      "spec": {
+        "type": "object",
+        "properties": {
+          "template": {
+            "type": "object",
+            "properties": {
+              "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+              "spec": {"$ref": "#/definitions/io.k8s.api.core.v1.PodSpec"}
+            }
+          }
+        }
+      }
+    }
+  },
+  "io.k8s.api.core.v1.Node": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "", "version": "v1", "kind": "Node"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+    }
+  },
+  "io.k8s.api.policy.v1.PodDisruptionBudget": {
+    "type": "object",
+    "x-kubernetes-group-version-kind": [{"group": "policy", "version": "v1", "kind": "PodDisruptionBudget"}],
+    "properties": {
+      "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+    }
+  },
+  "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+    "type": "object",
+    "properties": {
+      "finalizers": {
+        "type": "array",
+        "items": {"type": "string"},
+        "x-kubernetes-list-type": "set"
+      },
+      "ownerReferences": {
+        "type": "array",
+        "items": {"type": "object", "x-kubernetes-map-type": "atomic"},
+        "x-kubernetes-list-type": "map",
+        "x-kubernetes-list-map-keys": ["uid"]
+      }
+    }
+  },
+  "io.k8s.api.core.v1.PodSpec": {
+    "type": "object",
+    "properties": {
+      "containers": {"$ref": "#/definitions/io.k8s.api.core.v1.ContainerList"},
+      "initContainers": {"$ref": "#/definitions/io.k8s.api.core.v1.ContainerList"},
+      "ephemeralContainers": {"$ref": "#/definitions/io.k8s.api.core.v1.ContainerList"},
+      "volumes": {
+        "type": "array",
+        "items": {"type": "object"},
+        "x-kubernetes-list-type": "map",
+        "x-kubernetes-list-map-keys": ["name"]
+      }
+    }
+  },
+  "io.k8s.api.core.v1.ContainerList": {
+    "type": "array",
+    "items": {"$ref": "#/definitions/io.k8s.api.core.v1.Container"},
+    "x-kubernetes-list-type": "map",
+    "x-kubernetes-list-map-keys": ["name"]
+  },
+  "io.k8s.api.core.v1.Container": {
+    "type": "object",
+    "properties": {
+      "env": {
+        "type": "array",
+        "items": {"type": "object"},
+        "x-kubernetes-list-type": "map",
+        "x-kubernetes-list-map-keys": ["name"]
+      }
+    }
+  }
+}`
+
+var typeConverter = newTypeConverter()
+
+func newTypeConverter() managedfields.TypeConverter {
+	var definitions map[string]*spec.Schema
+	if err := json.Unmarshal([]byte(models), &definitions); err != nil {
+		panic(err.Error())
+	}
+
+	converter, err := managedfields.NewTypeConverter(definitions, true)
+	if err != nil {
+		panic(err.Error())
+	}
+	return converter
+}
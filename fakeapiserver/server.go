@@ -0,0 +1,673 @@
+// Package fakeapiserver is a tiny stand-in for the Kubernetes API server
+// serving ConfigMaps, Secrets, Pods, and Nodes (core/v1), Deployments
+// (apps/v1), and PodDisruptionBudgets (policy/v1) over real HTTPS.
+//
+// Unlike the fake clientsets, it exercises the whole client-go stack -
+// the REST client, the (de)serialization, the watch stream decoding,
+// discovery - so the example programs run against it unmodified:
+//
+//	server := fakeapiserver.NewServer()
+//	defer server.Close()
+//	server.WriteKubeconfig("/tmp/fakeapiserver.kubeconfig")
+//
+// Supported: discovery, get/list/watch/create/update/patch/delete/
+// deletecollection, label and field (metadata.name, metadata.namespace)
+// selectors, generateName, resourceVersion and optimistic locking, the
+// Deployments' generation, delete preconditions, dry-run, finalizers, the
+// garbage collection of the dependents (see deleteObject), server-side
+// apply (with a partial schema, see models), the status subresources,
+// pods/ephemeralcontainers, pods/binding, pods/eviction (honoring the
+// PodDisruptionBudgets), and deployments/scale. The cluster has a single Node, and the Pods bound
+// to it are running and ready right away. Not supported: authentication
+// and authorization, admission, defaulting and validation (beyond the
+// names), namespaces as objects, pagination (limit is ignored), and
+// anything else.
+package fakeapiserver
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+type Server struct {
+	*httptest.Server
+
+	store *store
+}
+
+// New returns an unstarted server. Call StartTLS() to start it (and,
+// optionally, replace the Listener before that).
+func New() *Server {
+	s := &Server{store: newStore()}
+	if _, err := s.store.create(resources["nodes"], node(), false); err != nil {
+		panic(err.Error())
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.EnableHTTP2 = true
+	return s
+}
+
+// NewServer returns a started server.
+func NewServer() *Server {
+	s := New()
+	s.StartTLS()
+	return s
+}
+
+// RESTConfig returns a config for the clients in the same process.
+func (s *Server) RESTConfig() *rest.Config {
+	return &rest.Config{
+		Host:            s.URL,
+		TLSClientConfig: rest.TLSClientConfig{CAData: s.caData()},
+		BearerToken:     "fake",
+	}
+}
+
+// Kubeconfig returns a kubeconfig with a single context "fakeapiserver".
+func (s *Server) Kubeconfig() *api.Config {
+	config := api.NewConfig()
+	config.Clusters["fakeapiserver"] = &api.Cluster{
+		Server:                   s.URL,
+		CertificateAuthorityData: s.caData(),
+	}
+	config.AuthInfos["fakeapiserver"] = &api.AuthInfo{Token: "fake"}
+	config.Contexts["fakeapiserver"] = &api.Context{
+		Cluster:   "fakeapiserver",
+		AuthInfo:  "fakeapiserver",
+		Namespace: "default",
+	}
+	config.CurrentContext = "fakeapiserver"
+	return config
+}
+
+func (s *Server) WriteKubeconfig(path string) error {
+	return clientcmd.WriteToFile(*s.Kubeconfig(), path)
+}
+
+func (s *Server) caData() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/version":
+		writeJSON(w, http.StatusOK, version.Info{
+			Major:      "1",
+			Minor:      "30",
+			GitVersion: "v1.30.0-fakeapiserver",
+			Platform:   "linux/amd64",
+		})
+
+	case r.URL.Path == "/livez" || r.URL.Path == "/readyz" || r.URL.Path == "/healthz":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, verbose := r.URL.Query()["verbose"]; verbose {
+			fmt.Fprintf(w, "[+]ping ok\n[+]etcd ok\n%s check passed\n", parts[0])
+		} else {
+			fmt.Fprint(w, "ok")
+		}
+
+	// Discovery. Asked for the aggregated discovery (client-go 0.26+ does it
+	// by default), a server may respond with the legacy format just fine.
+	case r.URL.Path == "/api":
+		writeJSON(w, http.StatusOK, metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+			ServerAddressByClientCIDRs: []metav1.ServerAddressByClientCIDR{
+				{ClientCIDR: "0.0.0.0/0", ServerAddress: r.Host},
+			},
+		})
+
+	case r.URL.Path == "/apis":
+		list := metav1.APIGroupList{
+			TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+			Groups:   []metav1.APIGroup{},
+		}
+		for _, gv := range groupVersions() {
+			if gv.Group != "" {
+				list.Groups = append(list.Groups, apiGroup(gv))
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	// /apis/<group>
+	case len(parts) == 2 && parts[0] == "apis":
+		for _, gv := range groupVersions() {
+			if gv.Group == parts[1] {
+				writeJSON(w, http.StatusOK, apiGroup(gv))
+				return
+			}
+		}
+		writeError(w, notFound(r))
+
+	default:
+		// /api/v1 and /apis/<group>/<version> prefix the rest.
+		gv, rest, ok := splitGroupVersion(parts)
+		if !ok {
+			writeError(w, notFound(r))
+			return
+		}
+		s.serveGroupVersion(w, r, gv, rest)
+	}
+}
+
+func (s *Server) serveGroupVersion(w http.ResponseWriter, r *http.Request, gv schema.GroupVersion, parts []string) {
+	if len(parts) == 0 {
+		writeJSON(w, http.StatusOK, apiResourceList(gv))
+		return
+	}
+
+	// <prefix>[/namespaces/<namespace>]/<resource>[/<name>[/<subresource>]]
+	ns := ""
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		ns, parts = parts[1], parts[2:]
+	}
+
+	res, ok := lookup(gv, parts[0])
+	if !ok || len(parts) > 3 || (res.clusterScoped && ns != "") {
+		writeError(w, notFound(r))
+		return
+	}
+
+	switch {
+	// Namespaced resources across all namespaces can only be read.
+	case !res.clusterScoped && ns == "":
+		if len(parts) != 1 || r.Method != http.MethodGet {
+			writeError(w, notFound(r))
+			return
+		}
+		s.serveCollection(w, r, res, "")
+
+	case len(parts) == 1:
+		s.serveCollection(w, r, res, ns)
+
+	case len(parts) == 2:
+		s.serveObject(w, r, res, ns, parts[1], "")
+
+	case res.handlers[parts[2]] != nil:
+		s.serveHandler(w, r, res, res.handlers[parts[2]], ns, parts[1])
+
+	case res.subresources[parts[2]] != nil:
+		s.serveObject(w, r, res, ns, parts[1], parts[2])
+
+	default:
+		writeError(w, notFound(r))
+	}
+}
+
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request, res *resource, ns string) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		sel, err := parseSelector(query)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if isWatch(query) {
+			s.serveWatch(w, r, res, ns, sel)
+			return
+		}
+
+		items, rv := s.store.list(res, ns, sel)
+		writeList(w, res, items, rv)
+
+	case http.MethodPost:
+		obj, err := decodeObject(r.Body, res)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(ns)
+		}
+		if obj.GetNamespace() != ns {
+			writeError(w, apierrors.NewBadRequest("the namespace of the provided object does not match the namespace sent on the request"))
+			return
+		}
+		if pod, ok := obj.(*corev1.Pod); ok && pod.Status.Phase == "" {
+			// Nothing is going to schedule it anyway.
+			pod.Status.Phase = corev1.PodPending
+		}
+		if res.prepareForCreate != nil {
+			res.prepareForCreate(obj)
+		}
+
+		created, err := s.store.create(res, obj, isDryRun(query))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeObject(w, http.StatusCreated, res, created)
+
+	case http.MethodDelete:
+		sel, err := parseSelector(query)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		opts, err := decodeDeleteOptions(r.Body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		items, _ := s.store.list(res, ns, sel)
+		var deleted []object
+		for _, item := range items {
+			obj, err := s.deleteObject(res, item.GetNamespace(), item.GetName(), opts, isDryRun(query) || isDryRunDelete(opts))
+			if apierrors.IsNotFound(err) {
+				continue // deleted concurrently
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			deleted = append(deleted, obj)
+		}
+
+		_, rv := s.store.list(res, ns, sel)
+		writeList(w, res, deleted, rv)
+
+	default:
+		writeError(w, methodNotSupported(r))
+	}
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, res *resource, ns, name, subresource string) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		obj, err := s.store.get(res, ns, name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeObject(w, http.StatusOK, res, obj)
+
+	case http.MethodPut, http.MethodPatch:
+		apply := r.Method == http.MethodPatch && isApply(r)
+		if apply && subresource != "" {
+			writeError(w, methodNotSupported(r))
+			return
+		}
+
+		stored, err := s.store.get(res, ns, name)
+		if apierrors.IsNotFound(err) && apply {
+			// Applying creates the object if it doesn't exist yet.
+			stored, err = res.new(), nil
+			stored.SetName(name)
+			stored.SetNamespace(ns)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var obj object
+		if r.Method == http.MethodPut {
+			obj, err = decodeObject(r.Body, res)
+		} else {
+			obj, err = patchObject(r, res, stored)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if apply && stored.GetUID() == "" {
+			if obj.GetName() != name {
+				writeError(w, apierrors.NewBadRequest("the name of the object does not match the request URL"))
+				return
+			}
+			if res.prepareForCreate != nil {
+				res.prepareForCreate(obj)
+			}
+			created, err := s.store.create(res, obj, isDryRun(query))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeObject(w, http.StatusCreated, res, created)
+			return
+		}
+
+		if obj.GetName() != name || (obj.GetNamespace() != "" && obj.GetNamespace() != ns) {
+			writeError(w, apierrors.NewBadRequest("the name or namespace of the object does not match the request URL"))
+			return
+		}
+		obj.SetNamespace(ns)
+
+		rv := obj.GetResourceVersion()
+		if subresource != "" {
+			obj = res.subresources[subresource](stored, obj)
+		} else if res.prepareForUpdate != nil {
+			res.prepareForUpdate(stored, obj)
+		}
+		// The subresource handlers start from the stored object - keep the
+		// resourceVersion of the request for the optimistic locking check.
+		obj.SetResourceVersion(rv)
+
+		updated, err := s.store.update(res, obj, isDryRun(query))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeObject(w, http.StatusOK, res, updated)
+
+	case http.MethodDelete:
+		if subresource != "" {
+			writeError(w, methodNotSupported(r))
+			return
+		}
+
+		opts, err := decodeDeleteOptions(r.Body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		deleted, err := s.deleteObject(res, ns, name, opts, isDryRun(query) || isDryRunDelete(opts))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if len(deleted.GetFinalizers()) > 0 {
+			// Not deleted yet - it's up to the finalizers now.
+			writeObject(w, http.StatusOK, res, deleted)
+			return
+		}
+		writeJSON(w, http.StatusOK, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+			Details: &metav1.StatusDetails{
+				Name: deleted.GetName(),
+				Kind: res.name,
+				UID:  deleted.GetUID(),
+			},
+		})
+
+	default:
+		writeError(w, methodNotSupported(r))
+	}
+}
+
+// serveHandler serves the subresources of a kind of their own (see
+// handler) - only for the objects that exist.
+func (s *Server) serveHandler(w http.ResponseWriter, r *http.Request, res *resource, h *handler, ns, name string) {
+	verb := map[string]string{
+		http.MethodGet:   "get",
+		http.MethodPost:  "create",
+		http.MethodPut:   "update",
+		http.MethodPatch: "patch",
+	}[r.Method]
+	if !slices.Contains(h.verbs, verb) {
+		writeError(w, methodNotSupported(r))
+		return
+	}
+
+	stored, err := s.store.get(res, ns, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.serve(s, w, r, res, stored)
+}
+
+// serveWatch streams the matching events as newline-delimited JSON
+// WatchEvents, flushing after every event (chunked encoding in HTTP/1.1).
+//
+// Without resourceVersion (or with "0"), the stream starts with synthetic
+// ADDED events for the existing objects. Otherwise, it replays the changes
+// made after that version.
+func (s *Server) serveWatch(w http.ResponseWriter, r *http.Request, res *resource, ns string, sel selector) {
+	query := r.URL.Query()
+
+	var initial []object
+	var pos int
+	switch rv := query.Get("resourceVersion"); rv {
+	case "", "0":
+		var listRV int64
+		initial, listRV = s.store.list(res, ns, sel)
+		pos = s.store.position(listRV)
+	default:
+		n, err := strconv.ParseInt(rv, 10, 64)
+		if err != nil {
+			writeError(w, apierrors.NewBadRequest(fmt.Sprintf("invalid resourceVersion %q", rv)))
+			return
+		}
+		pos = s.store.position(n)
+	}
+
+	timeout := time.After(30 * time.Minute)
+	if seconds, err := strconv.Atoi(query.Get("timeoutSeconds")); err == nil && seconds > 0 {
+		timeout = time.After(time.Duration(seconds) * time.Second)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	flusher := w.(http.Flusher)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	send := func(typ string, obj object) bool {
+		res.setTypeMeta(obj)
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return false
+		}
+		if err := encoder.Encode(metav1.WatchEvent{Type: typ, Object: runtime.RawExtension{Raw: data}}); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	for _, obj := range initial {
+		if !send(string(watch.Added), obj) {
+			return
+		}
+	}
+
+	for {
+		events, next, changed := s.store.eventsSince(pos)
+		pos = next
+
+		for _, e := range events {
+			if e.resource != res.name {
+				continue
+			}
+			if ns != "" && e.object.GetNamespace() != ns {
+				continue
+			}
+
+			// An object starting or stopping to match the selector is
+			// seen as added or deleted by the watcher.
+			typ, obj := e.typ, e.object
+			matchesNow, matchedBefore := sel.matches(e.object), sel.matches(e.old)
+			switch {
+			case e.typ == watch.Modified && matchesNow && !matchedBefore:
+				typ = watch.Added
+			case e.typ == watch.Modified && !matchesNow && matchedBefore:
+				typ = watch.Deleted
+			case e.typ == watch.Deleted && !matchedBefore:
+				continue
+			case e.typ != watch.Deleted && !matchesNow:
+				continue
+			}
+
+			if !send(string(typ), obj.DeepCopyObject().(object)) {
+				return
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-timeout:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func parseSelector(query url.Values) (selector, error) {
+	labelSelector, err := labels.Parse(query.Get("labelSelector"))
+	if err != nil {
+		return selector{}, apierrors.NewBadRequest(err.Error())
+	}
+
+	fieldSelector, err := fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		return selector{}, apierrors.NewBadRequest(err.Error())
+	}
+	for _, req := range fieldSelector.Requirements() {
+		if req.Field != "metadata.name" && req.Field != "metadata.namespace" {
+			return selector{}, apierrors.NewBadRequest(fmt.Sprintf("field label not supported: %s", req.Field))
+		}
+	}
+
+	return selector{labels: labelSelector, fields: fieldSelector}, nil
+}
+
+func decodeObject(body io.Reader, res *resource) (object, error) {
+	obj := res.new()
+	if err := json.NewDecoder(body).Decode(obj); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind != "" && gvk.Kind != res.kind {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected kind %s, got %s", res.kind, gvk.Kind))
+	}
+
+	// Only the metadata and the payload are stored.
+	obj.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	return obj, nil
+}
+
+func decodeDeleteOptions(body io.Reader) (*metav1.DeleteOptions, error) {
+	opts := &metav1.DeleteOptions{}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, opts); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	}
+	return opts, nil
+}
+
+func patchObject(r *http.Request, res *resource, stored object) (object, error) {
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	// The same status codes the real API server responds with.
+	var patched []byte
+	switch patchType(r) {
+	case types.JSONPatchType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		if patched, err = ops.Apply(original); err != nil {
+			// E.g., a failed "test" operation.
+			return nil, apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "", schema.GroupResource{}, "", err.Error(), 0, false)
+		}
+	case types.MergePatchType:
+		if patched, err = jsonpatch.MergePatch(original, patch); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.StrategicMergePatchType:
+		if patched, err = strategicpatch.StrategicMergePatch(original, patch, res.new()); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.ApplyPatchType:
+		return applyObject(patch, r.URL.Query(), res, stored)
+	default:
+		return nil, apierrors.NewGenericServerResponse(
+			http.StatusUnsupportedMediaType, "patch", groupResource(res), stored.GetName(),
+			fmt.Sprintf("the body of the request was in an unknown format - accepted media types include: %s, %s, %s, %s",
+				types.JSONPatchType, types.MergePatchType, types.StrategicMergePatchType, types.ApplyPatchType),
+			0, false,
+		)
+	}
+
+	return decodeObject(strings.NewReader(string(patched)), res)
+}
+
+func patchType(r *http.Request) types.PatchType {
+	return types.PatchType(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+}
+
+// splitGroupVersion splits /api/v1/... and /apis/<group>/<version>/... paths
+// of the served group versions into the group version and the rest.
+func splitGroupVersion(parts []string) (schema.GroupVersion, []string, bool) {
+	var gv schema.GroupVersion
+	var rest []string
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		gv, rest = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		gv, rest = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return gv, nil, false
+	}
+
+	for _, served := range groupVersions() {
+		if served == gv {
+			return gv, rest, true
+		}
+	}
+	return gv, nil, false
+}
+
+func apiGroup(gv schema.GroupVersion) metav1.APIGroup {
+	version := metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+	return metav1.APIGroup{
+		TypeMeta:         metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"},
+		Name:             gv.Group,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	}
+}
@@ -0,0 +1,772 @@
+package fakeapiserver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+)
+
+var ctx = context.Background()
+
+func newClient(t *testing.T) kubernetes.Interface {
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	return kubernetes.NewForConfigOrDie(server.RESTConfig())
+}
+
+func newConfigMap(name string, labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Data:       map[string]string{"foo": "bar"},
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	client := newClient(t)
+
+	ver, err := client.Discovery().ServerVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ver.GitVersion == "" {
+		t.Error("expected a server version")
+	}
+
+	list, err := client.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, r := range list.APIResources {
+		found[r.Name] = true
+	}
+	for _, name := range []string{"configmaps", "nodes", "nodes/status", "pods", "pods/binding", "pods/eviction", "pods/status", "pods/ephemeralcontainers"} {
+		if !found[name] {
+			t.Errorf("expected %s in discovery, got %v", name, list.APIResources)
+		}
+	}
+
+	// What the dynamic clients and kubectl use to map kinds to resources.
+	resources, err := restmapper.GetAPIGroupResources(client.Discovery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := restmapper.NewDiscoveryRESTMapper(resources).RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Resource.String() != "apps/v1, Resource=deployments" {
+		t.Errorf("unexpected mapping of Deployments: %v", mapping.Resource)
+	}
+
+	mapping, err = restmapper.NewDiscoveryRESTMapper(resources).RESTMapping(schema.GroupKind{Kind: "Node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameRoot {
+		t.Errorf("expected Nodes to be cluster-scoped, got %v", mapping.Scope.Name())
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	configMaps := newClient(t).CoreV1().ConfigMaps("default")
+
+	desired := newConfigMap("", nil)
+	desired.GenerateName = "crud-"
+
+	created, err := configMaps.Create(ctx, desired, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Name) != len("crud-")+5 || created.UID == "" || created.ResourceVersion == "" {
+		t.Errorf("expected a generated name, uid, and resourceVersion, got %+v", created.ObjectMeta)
+	}
+
+	if _, err := configMaps.Create(ctx, newConfigMap(created.Name, nil), metav1.CreateOptions{}); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected AlreadyExists, got %v", err)
+	}
+	if _, err := configMaps.Create(ctx, newConfigMap("", nil), metav1.CreateOptions{}); !apierrors.IsInvalid(err) {
+		t.Errorf("expected Invalid, got %v", err)
+	}
+
+	read, err := configMaps.Get(ctx, created.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read.Data["foo"] = "baz"
+	updated, err := configMaps.Update(ctx, read, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ResourceVersion == read.ResourceVersion {
+		t.Error("expected the update to bump the resourceVersion")
+	}
+
+	// read is stale now.
+	read.Data["foo"] = "qux"
+	if _, err := configMaps.Update(ctx, read, metav1.UpdateOptions{}); !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict, got %v", err)
+	}
+
+	// A no-op update keeps the version.
+	same, err := configMaps.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("expected a no-op update to keep resourceVersion %s, got %s", updated.ResourceVersion, same.ResourceVersion)
+	}
+
+	stale := "1"
+	err = configMaps.Delete(ctx, created.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &stale}})
+	if !apierrors.IsConflict(err) {
+		t.Errorf("expected the delete precondition to fail, got %v", err)
+	}
+
+	if err := configMaps.Delete(ctx, created.Name, metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configMaps.Get(ctx, created.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the dry-run delete to keep the object, got %v", err)
+	}
+
+	if err := configMaps.Delete(ctx, created.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configMaps.Get(ctx, created.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	client := newClient(t)
+
+	for _, cm := range []*corev1.ConfigMap{
+		newConfigMap("a", map[string]string{"app": "foo", "tier": "web"}),
+		newConfigMap("b", map[string]string{"app": "foo", "tier": "db"}),
+		newConfigMap("c", map[string]string{"app": "bar"}),
+	} {
+		if _, err := client.CoreV1().ConfigMaps("default").Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.CoreV1().ConfigMaps("other").Create(ctx, newConfigMap("a", map[string]string{"app": "foo"}), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		namespace string
+		opts      metav1.ListOptions
+		want      int
+	}{
+		{"default", metav1.ListOptions{}, 3},
+		{"", metav1.ListOptions{}, 4},
+		{"default", metav1.ListOptions{LabelSelector: "app=foo"}, 2},
+		{"default", metav1.ListOptions{LabelSelector: "app=foo,tier!=db"}, 1},
+		{"default", metav1.ListOptions{LabelSelector: "tier"}, 2},
+		{"", metav1.ListOptions{LabelSelector: "app in (foo)"}, 3},
+		{"", metav1.ListOptions{FieldSelector: "metadata.name=a"}, 2},
+		{"", metav1.ListOptions{FieldSelector: "metadata.name=a,metadata.namespace!=default"}, 1},
+	} {
+		list, err := client.CoreV1().ConfigMaps(tc.namespace).List(ctx, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Items) != tc.want {
+			t.Errorf("namespace %q, %+v: expected %d items, got %d", tc.namespace, tc.opts, tc.want, len(list.Items))
+		}
+	}
+
+	_, err := client.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{FieldSelector: "data.foo=bar"})
+	if !apierrors.IsBadRequest(err) {
+		t.Errorf("expected BadRequest for an unsupported field selector, got %v", err)
+	}
+
+	if err := client.CoreV1().ConfigMaps("default").DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "app=foo"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := client.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("expected 2 ConfigMaps after deleting the collection, got %d", len(list.Items))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	configMaps := newClient(t).CoreV1().ConfigMaps("default")
+
+	existing, err := configMaps.Create(ctx, newConfigMap("existing", map[string]string{"watched": "yes"}), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := configMaps.Watch(ctx, metav1.ListOptions{LabelSelector: "watched=yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	if _, err := configMaps.Create(ctx, newConfigMap("ignored", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := configMaps.Create(ctx, newConfigMap("second", map[string]string{"watched": "yes"}), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stops matching the selector - seen as deleted.
+	second.Labels["watched"] = "no"
+	if _, err := configMaps.Update(ctx, second, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configMaps.Delete(ctx, existing.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvents(t, watcher, []string{
+		"ADDED existing",
+		"ADDED second",
+		"DELETED second",
+		"DELETED existing",
+	})
+
+	// Starting from a resourceVersion replays the later changes.
+	replay, err := configMaps.Watch(ctx, metav1.ListOptions{ResourceVersion: existing.ResourceVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Stop()
+
+	expectEvents(t, replay, []string{
+		"ADDED ignored",
+		"ADDED second",
+		"MODIFIED second",
+		"DELETED existing",
+	})
+}
+
+func expectEvents(t *testing.T, watcher watch.Interface, want []string) {
+	t.Helper()
+
+	for _, w := range want {
+		select {
+		case event := <-watcher.ResultChan():
+			cm, ok := event.Object.(*corev1.ConfigMap)
+			if !ok {
+				t.Fatalf("unexpected watch event %v %#v", event.Type, event.Object)
+			}
+			if got := string(event.Type) + " " + cm.Name; got != w {
+				t.Errorf("expected %q, got %q", w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func TestFinalizers(t *testing.T) {
+	configMaps := newClient(t).CoreV1().ConfigMaps("default")
+
+	desired := newConfigMap("finalized", nil)
+	desired.Finalizers = []string{"example.com/cleanup"}
+	if _, err := configMaps.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configMaps.Delete(ctx, "finalized", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := configMaps.Get(ctx, "finalized", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.DeletionTimestamp == nil {
+		t.Error("expected the deleted ConfigMap with a finalizer to stay, marked with deletionTimestamp")
+	}
+
+	// Removing the last finalizer completes the deletion.
+	cm.Finalizers = nil
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configMaps.Get(ctx, "finalized", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the ConfigMap to be gone with the last finalizer, got %v", err)
+	}
+}
+
+func TestGarbageCollection(t *testing.T) {
+	for policy, want := range map[metav1.DeletionPropagation][]string{
+		metav1.DeletePropagationBackground: {
+			"DELETED owner",
+			"DELETED blocking",
+			"DELETED loose",
+			"MODIFIED shared",
+		},
+		// Only the dependents blocking the owner's deletion go before it.
+		metav1.DeletePropagationForeground: {
+			"MODIFIED owner",
+			"MODIFIED blocking",
+			"DELETED blocking",
+			"DELETED owner",
+			"DELETED loose",
+			"MODIFIED shared",
+		},
+		metav1.DeletePropagationOrphan: {
+			"MODIFIED owner",
+			"MODIFIED blocking",
+			"MODIFIED loose",
+			"MODIFIED shared",
+			"DELETED owner",
+		},
+	} {
+		t.Run(string(policy), func(t *testing.T) {
+			configMaps := newClient(t).CoreV1().ConfigMaps("default")
+
+			var owners []*corev1.ConfigMap
+			for _, name := range []string{"owner", "other"} {
+				cm, err := configMaps.Create(ctx, newConfigMap(name, nil), metav1.CreateOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				owners = append(owners, cm)
+			}
+
+			blocking := true
+			ref := func(owner *corev1.ConfigMap, block *bool) metav1.OwnerReference {
+				return metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: owner.Name, UID: owner.UID, BlockOwnerDeletion: block}
+			}
+
+			for name, refs := range map[string][]metav1.OwnerReference{
+				"blocking": {ref(owners[0], &blocking)},
+				"loose":    {ref(owners[0], nil)},
+				"shared":   {ref(owners[0], nil), ref(owners[1], nil)},
+			} {
+				desired := newConfigMap(name, nil)
+				desired.OwnerReferences = refs
+				if _, err := configMaps.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			list, err := configMaps.List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			watcher, err := configMaps.Watch(ctx, metav1.ListOptions{ResourceVersion: list.ResourceVersion})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer watcher.Stop()
+
+			if err := configMaps.Delete(ctx, "owner", metav1.DeleteOptions{PropagationPolicy: &policy}); err != nil {
+				t.Fatal(err)
+			}
+			expectEvents(t, watcher, want)
+
+			shared, err := configMaps.Get(ctx, "shared", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shared.OwnerReferences) != 1 || shared.OwnerReferences[0].UID != owners[1].UID {
+				t.Errorf("expected the shared dependent to keep only the other owner, got %v", shared.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	client := newClient(t)
+	configMaps := client.CoreV1().ConfigMaps("default")
+
+	if _, err := configMaps.Create(ctx, newConfigMap("patched", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := configMaps.Patch(ctx, "patched", types.MergePatchType, []byte(`{"data":{"foo":null,"qux":"1"}}`), metav1.PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data["foo"]; ok || cm.Data["qux"] != "1" {
+		t.Errorf("unexpected data after the merge patch: %v", cm.Data)
+	}
+
+	cm, err = configMaps.Patch(ctx, "patched", types.JSONPatchType, []byte(`[{"op":"test","path":"/data/qux","value":"1"},{"op":"replace","path":"/data/qux","value":"2"}]`), metav1.PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["qux"] != "2" {
+		t.Errorf("unexpected data after the JSON patch: %v", cm.Data)
+	}
+
+	_, err = configMaps.Patch(ctx, "patched", types.JSONPatchType, []byte(`[{"op":"test","path":"/data/qux","value":"1"}]`), metav1.PatchOptions{})
+	if statusErr, ok := err.(apierrors.APIStatus); !ok || statusErr.Status().Code != 422 {
+		t.Errorf("expected 422 for a failed test operation, got %v", err)
+	}
+
+	_, err = configMaps.Patch(ctx, "patched", types.MergePatchType, []byte(`{"metadata":{"resourceVersion":"1"}}`), metav1.PatchOptions{})
+	if !apierrors.IsConflict(err) {
+		t.Errorf("expected a stale resourceVersion in the patch to conflict, got %v", err)
+	}
+
+	_, err = configMaps.Patch(ctx, "patched", types.PatchType("application/yaml"), []byte(`{}`), metav1.PatchOptions{})
+	if statusErr, ok := err.(apierrors.APIStatus); !ok || statusErr.Status().Code != 415 {
+		t.Errorf("expected 415 for an unknown patch type, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	configMaps := newClient(t).CoreV1().ConfigMaps("default")
+
+	apply := func(manager string, force bool, data string) (*corev1.ConfigMap, error) {
+		patch := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"applied"},"data":` + data + `}`
+		return configMaps.Patch(ctx, "applied", types.ApplyPatchType, []byte(patch), metav1.PatchOptions{FieldManager: manager, Force: &force})
+	}
+
+	// Applying creates the object...
+	cm, err := apply("first", false, `{"foo":"1","bar":"1"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.UID == "" || len(cm.Data) != 2 {
+		t.Errorf("expected the object to be created, got %+v", cm)
+	}
+	if len(cm.ManagedFields) != 1 || cm.ManagedFields[0].Manager != "first" || cm.ManagedFields[0].Operation != metav1.ManagedFieldsOperationApply {
+		t.Errorf("expected the fields to be owned by the first manager, got %+v", cm.ManagedFields)
+	}
+
+	// ...and then merges into it, unless the fields are owned by someone else.
+	if _, err := apply("second", false, `{"foo":"2","qux":"2"}`); !apierrors.IsConflict(err) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	if cm, err = apply("second", false, `{"foo":"1","qux":"2"}`); err != nil {
+		t.Errorf("expected the same value to be co-owned without a conflict, got %v", err)
+	}
+	if cm, err = apply("second", true, `{"foo":"2","qux":"2"}`); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["foo"] != "2" || cm.Data["bar"] != "1" || cm.Data["qux"] != "2" {
+		t.Errorf("unexpected data after the forced apply: %v", cm.Data)
+	}
+
+	// The fields a manager owns alone disappear when it stops applying them.
+	if cm, err = apply("first", false, `{}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data["bar"]; ok || cm.Data["foo"] != "2" {
+		t.Errorf("expected only bar to be removed, got %v", cm.Data)
+	}
+
+	if _, err := apply("", false, `{}`); !apierrors.IsBadRequest(err) {
+		t.Errorf("expected a missing field manager to be rejected, got %v", err)
+	}
+	if _, err := configMaps.Patch(ctx, "applied", types.ApplyPatchType, []byte(`{"metadata":{"name":"applied"}}`), metav1.PatchOptions{FieldManager: "first"}); !apierrors.IsBadRequest(err) {
+		t.Errorf("expected a missing kind to be rejected, got %v", err)
+	}
+}
+
+func TestApplyMergesListsByKeys(t *testing.T) {
+	client := newClient(t)
+	deployments := client.AppsV1().Deployments("default")
+
+	labels := map[string]string{"app": "web"}
+	_, err := deployments.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apply := func(containers string) *appsv1.Deployment {
+		t.Helper()
+
+		patch := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web"},"spec":{"template":{"spec":{"containers":` + containers + `}}}}`
+		deployment, err := deployments.Patch(ctx, "web", types.ApplyPatchType, []byte(patch), metav1.PatchOptions{FieldManager: "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deployment
+	}
+
+	containerNames := func(deployment *appsv1.Deployment) (names []string) {
+		for _, c := range deployment.Spec.Template.Spec.Containers {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	// The containers are a map keyed by name, not an atomic list.
+	deployment := apply(`[{"name":"sidecar","image":"busybox"}]`)
+	if got := containerNames(deployment); !reflect.DeepEqual(got, []string{"app", "sidecar"}) {
+		t.Errorf("expected the sidecar to be added, got %v", got)
+	}
+
+	deployment = apply(`[]`)
+	if got := containerNames(deployment); !reflect.DeepEqual(got, []string{"app"}) {
+		t.Errorf("expected only the sidecar to be removed, got %v", got)
+	}
+
+	list, err := client.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "web" {
+		t.Errorf("expected the Deployment in the list across namespaces, got %+v", list.Items)
+	}
+}
+
+func TestPodSubresources(t *testing.T) {
+	pods := newClient(t).CoreV1().Pods("default")
+
+	pod, err := pods.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "alpine"}}},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.Status.Phase != corev1.PodPending {
+		t.Errorf("expected a Pending pod, got %q", pod.Status.Phase)
+	}
+
+	// The main resource ignores status changes...
+	pod.Status.Phase = corev1.PodRunning
+	if pod, err = pods.Update(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if pod.Status.Phase != corev1.PodPending {
+		t.Errorf("expected the update to keep the status, got %q", pod.Status.Phase)
+	}
+
+	// ...that go to the status subresource.
+	pod.Status.Phase = corev1.PodRunning
+	pod.Labels = map[string]string{"ignored": "yes"}
+	if pod, err = pods.UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if pod.Status.Phase != corev1.PodRunning || len(pod.Labels) != 0 {
+		t.Errorf("expected only the status to change, got %q and %v", pod.Status.Phase, pod.Labels)
+	}
+
+	pod, err = pods.Patch(ctx, "pod", types.StrategicMergePatchType,
+		[]byte(`{"spec":{"ephemeralContainers":[{"name":"debugger","image":"busybox"}]}}`),
+		metav1.PatchOptions{}, "ephemeralcontainers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.EphemeralContainers) != 1 || len(pod.Spec.Containers) != 1 {
+		t.Errorf("unexpected containers after the patch: %+v", pod.Spec)
+	}
+}
+
+func TestDeploymentSubresources(t *testing.T) {
+	deployments := newClient(t).AppsV1().Deployments("default")
+
+	labels := map[string]string{"app": "scaled"}
+	deployment, err := deployments.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "scaled"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "alpine"}}},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scale, err := deployments.GetScale(ctx, "scaled", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scale.Spec.Replicas != 0 || scale.Status.Selector != "app=scaled" || scale.ResourceVersion != deployment.ResourceVersion {
+		t.Errorf("unexpected scale of the Deployment: %+v", scale)
+	}
+	if deployment.Generation != 1 {
+		t.Errorf("expected a new Deployment at generation 1, got %d", deployment.Generation)
+	}
+
+	scale.Spec.Replicas = 3
+	if scale, err = deployments.UpdateScale(ctx, "scaled", scale, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if deployment, err = deployments.Get(ctx, "scaled", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 3 || scale.ResourceVersion != deployment.ResourceVersion {
+		t.Errorf("expected the Deployment scaled to 3 at %s, got %d at %s", scale.ResourceVersion, *deployment.Spec.Replicas, deployment.ResourceVersion)
+	}
+	if deployment.Generation != 2 {
+		t.Errorf("expected scaling to bump the generation to 2, got %d", deployment.Generation)
+	}
+
+	scale.ResourceVersion = "1"
+	if _, err := deployments.UpdateScale(ctx, "scaled", scale, metav1.UpdateOptions{}); !apierrors.IsConflict(err) {
+		t.Errorf("expected a stale scale to conflict, got %v", err)
+	}
+
+	// The status subresource ignores the spec changes.
+	replicas := int32(5)
+	deployment.Spec.Replicas = &replicas
+	deployment.Status.Replicas = 3
+	if deployment, err = deployments.UpdateStatus(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 3 || deployment.Status.Replicas != 3 {
+		t.Errorf("expected only the status to change, got %d and %d", *deployment.Spec.Replicas, deployment.Status.Replicas)
+	}
+	if deployment.Generation != 2 {
+		t.Errorf("expected the status update to keep the generation at 2, got %d", deployment.Generation)
+	}
+
+	// So do the label changes, but not the spec ones.
+	deployment, err = deployments.Patch(ctx, "scaled", types.MergePatchType, []byte(`{"metadata":{"labels":{"tier":"web"}}}`), metav1.PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Generation != 2 {
+		t.Errorf("expected the label change to keep the generation at 2, got %d", deployment.Generation)
+	}
+	deployment, err = deployments.Patch(ctx, "scaled", types.MergePatchType, []byte(`{"spec":{"paused":true}}`), metav1.PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Generation != 3 {
+		t.Errorf("expected the spec change to bump the generation to 3, got %d", deployment.Generation)
+	}
+}
+
+func TestBindingAndEviction(t *testing.T) {
+	client := newClient(t)
+	pods := client.CoreV1().Pods("default")
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes.Items) != 1 {
+		t.Fatalf("expected a single Node, got %d", len(nodes.Items))
+	}
+
+	labels := map[string]string{"app": "evicted"}
+	for _, name := range []string{"bound", "pending"} {
+		_, err := pods.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "alpine"}}},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	binding := &corev1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Name: "bound"},
+		Target:     corev1.ObjectReference{Kind: "Node", Name: nodes.Items[0].Name},
+	}
+	if err := pods.Bind(ctx, binding, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pods.Bind(ctx, binding, metav1.CreateOptions{}); !apierrors.IsConflict(err) {
+		t.Errorf("expected binding a bound Pod to conflict, got %v", err)
+	}
+
+	pod, err := pods.Get(ctx, "bound", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.Spec.NodeName != nodes.Items[0].Name || !isHealthy(pod) {
+		t.Errorf("expected the bound Pod running and ready, got %q and %+v", pod.Spec.NodeName, pod.Status)
+	}
+
+	_, err = client.PolicyV1().PodDisruptionBudgets("default").Create(ctx, &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "budget"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+			Selector:     &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only healthy Pod can't go...
+	err = pods.EvictV1(ctx, &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: "bound"}})
+	if !apierrors.IsTooManyRequests(err) || !apierrors.HasStatusCause(err, policyv1.DisruptionBudgetCause) {
+		t.Errorf("expected the eviction to violate the budget, got %v", err)
+	}
+
+	// ...but the pending one can.
+	if err := pods.EvictV1(ctx, &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: "pending"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pods.Get(ctx, "pending", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the evicted Pod to be deleted, got %v", err)
+	}
+}
+
+func TestInformer(t *testing.T) {
+	client := newClient(t)
+
+	if _, err := client.CoreV1().ConfigMaps("default").Create(ctx, newConfigMap("before", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Core().V1().ConfigMaps()
+
+	added := make(chan string, 10)
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			added <- obj.(*corev1.ConfigMap).Name
+		},
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+
+	if _, err := client.CoreV1().ConfigMaps("default").Create(ctx, newConfigMap("after", nil), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"before", "after"} {
+		select {
+		case name := <-added:
+			if name != want {
+				t.Errorf("expected %q added, got %q", want, name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q to be added", want)
+		}
+	}
+}
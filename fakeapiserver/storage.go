@@ -0,0 +1,286 @@
+package fakeapiserver
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// store is what etcd is for the real API server. Every change bumps the
+// global resourceVersion and is appended to the event log watches are
+// served from. The log is never compacted, so a watch can start from any
+// resourceVersion.
+type store struct {
+	mu      sync.Mutex
+	rv      int64
+	objects map[string]map[types.NamespacedName]object
+	events  []event
+	changed chan struct{} // closed and replaced on every change
+}
+
+type event struct {
+	resource string
+	typ      watch.EventType
+	object   object
+	old      object // the state before the change, nil for ADDED
+	rv       int64
+}
+
+func newStore() *store {
+	return &store{
+		objects: map[string]map[types.NamespacedName]object{},
+		changed: make(chan struct{}),
+	}
+}
+
+type selector struct {
+	labels labels.Selector
+	fields fields.Selector
+}
+
+func (s selector) matches(obj object) bool {
+	if obj == nil {
+		return false
+	}
+	return s.labels.Matches(labels.Set(obj.GetLabels())) &&
+		s.fields.Matches(fields.Set{
+			"metadata.name":      obj.GetName(),
+			"metadata.namespace": obj.GetNamespace(),
+		})
+}
+
+func (s *store) get(r *resource, ns, name string) (object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[r.name][types.NamespacedName{Namespace: ns, Name: name}]
+	if !ok {
+		return nil, apierrors.NewNotFound(groupResource(r), name)
+	}
+	return obj.DeepCopyObject().(object), nil
+}
+
+// list returns the matching objects sorted by namespace and name, and
+// the resourceVersion the list is consistent with. An empty ns means all
+// namespaces.
+func (s *store) list(r *resource, ns string, sel selector) ([]object, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []object
+	for key, obj := range s.objects[r.name] {
+		if (ns == "" || key.Namespace == ns) && sel.matches(obj) {
+			items = append(items, obj.DeepCopyObject().(object))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
+	})
+	return items, s.rv
+}
+
+func (s *store) create(r *resource, obj object, dryRun bool) (object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if obj.GetName() == "" && obj.GetGenerateName() == "" {
+		return nil, apierrors.NewInvalid(groupKind(r), "", field.ErrorList{
+			field.Required(field.NewPath("metadata", "name"), "name or generateName is required"),
+		})
+	}
+	if obj.GetName() == "" {
+		// Unlike the real API server, retry on collisions instead of
+		// returning 409 AlreadyExists.
+		for {
+			name := obj.GetGenerateName() + rand.String(5)
+			if !s.exists(r, obj.GetNamespace(), name) {
+				obj.SetName(name)
+				break
+			}
+		}
+	}
+	if s.exists(r, obj.GetNamespace(), obj.GetName()) {
+		return nil, apierrors.NewAlreadyExists(groupResource(r), obj.GetName())
+	}
+
+	obj.SetUID(uuid.NewUUID())
+	obj.SetCreationTimestamp(metav1.Now())
+	obj.SetResourceVersion("")
+	obj.SetDeletionTimestamp(nil)
+	obj.SetDeletionGracePeriodSeconds(nil)
+	if dryRun {
+		return obj, nil
+	}
+
+	s.commit(r, watch.Added, obj, nil)
+	return obj, nil
+}
+
+// update replaces the stored object with obj. A non-empty
+// obj.resourceVersion must match the stored one (optimistic locking).
+// The object being deleted is gone once its last finalizer is removed.
+func (s *store) update(r *resource, obj object, dryRun bool) (object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	stored, ok := s.objects[r.name][key]
+	if !ok {
+		return nil, apierrors.NewNotFound(groupResource(r), obj.GetName())
+	}
+	if rv := obj.GetResourceVersion(); rv != "" && rv != stored.GetResourceVersion() {
+		return nil, conflict(r, obj.GetName())
+	}
+
+	obj.SetUID(stored.GetUID())
+	obj.SetCreationTimestamp(stored.GetCreationTimestamp())
+	obj.SetResourceVersion(stored.GetResourceVersion())
+	obj.SetDeletionTimestamp(stored.GetDeletionTimestamp())
+	obj.SetDeletionGracePeriodSeconds(stored.GetDeletionGracePeriodSeconds())
+
+	// No-op updates don't produce new versions (and watch events).
+	if equality.Semantic.DeepEqual(obj, stored) || dryRun {
+		return obj, nil
+	}
+
+	if obj.GetDeletionTimestamp() != nil && len(obj.GetFinalizers()) == 0 {
+		s.commit(r, watch.Deleted, obj, stored)
+		return obj, nil
+	}
+
+	s.commit(r, watch.Modified, obj, stored)
+	return obj, nil
+}
+
+// delete deletes the object right away, unless it has finalizers (the
+// given ones are added to the object's own) - then it's only marked with
+// deletionTimestamp, and the last finalizer removed deletes it (see update).
+func (s *store) delete(r *resource, ns, name string, preconditions *metav1.Preconditions, dryRun bool, finalizers ...string) (object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.objects[r.name][types.NamespacedName{Namespace: ns, Name: name}]
+	if !ok {
+		return nil, apierrors.NewNotFound(groupResource(r), name)
+	}
+
+	if preconditions != nil {
+		if preconditions.UID != nil && *preconditions.UID != stored.GetUID() {
+			return nil, apierrors.NewConflict(groupResource(r), name, fmt.Errorf(
+				"Precondition failed: UID in precondition: %v, UID in object meta: %v", *preconditions.UID, stored.GetUID()))
+		}
+		if preconditions.ResourceVersion != nil && *preconditions.ResourceVersion != stored.GetResourceVersion() {
+			return nil, apierrors.NewConflict(groupResource(r), name, fmt.Errorf(
+				"Precondition failed: ResourceVersion in precondition: %v, ResourceVersion in object meta: %v", *preconditions.ResourceVersion, stored.GetResourceVersion()))
+		}
+	}
+
+	obj := stored.DeepCopyObject().(object)
+	for _, f := range finalizers {
+		if !slices.Contains(obj.GetFinalizers(), f) {
+			obj.SetFinalizers(append(obj.GetFinalizers(), f))
+		}
+	}
+
+	if len(obj.GetFinalizers()) == 0 {
+		if !dryRun {
+			s.commit(r, watch.Deleted, obj, stored)
+		}
+		return obj, nil
+	}
+
+	if obj.GetDeletionTimestamp() == nil {
+		now, gracePeriod := metav1.Now(), int64(0)
+		obj.SetDeletionTimestamp(&now)
+		obj.SetDeletionGracePeriodSeconds(&gracePeriod)
+	}
+	if !equality.Semantic.DeepEqual(obj, stored) && !dryRun {
+		s.commit(r, watch.Modified, obj, stored)
+	}
+	return obj, nil
+}
+
+// commit must be called with the lock held. It assigns obj a new
+// resourceVersion.
+func (s *store) commit(r *resource, typ watch.EventType, obj, old object) {
+	s.rv++
+	obj.SetResourceVersion(strconv.FormatInt(s.rv, 10))
+
+	if s.objects[r.name] == nil {
+		s.objects[r.name] = map[types.NamespacedName]object{}
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if typ == watch.Deleted {
+		delete(s.objects[r.name], key)
+	} else {
+		s.objects[r.name][key] = obj.DeepCopyObject().(object)
+	}
+
+	var oldCopy object
+	if old != nil {
+		oldCopy = old.DeepCopyObject().(object)
+	}
+	s.events = append(s.events, event{
+		resource: r.name,
+		typ:      typ,
+		object:   obj.DeepCopyObject().(object),
+		old:      oldCopy,
+		rv:       s.rv,
+	})
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *store) exists(r *resource, ns, name string) bool {
+	_, ok := s.objects[r.name][types.NamespacedName{Namespace: ns, Name: name}]
+	return ok
+}
+
+// eventsSince returns the events after the given position in the log,
+// the new position, and a channel closed on the next change.
+func (s *store) eventsSince(pos int) ([]event, int, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.events[pos:], len(s.events), s.changed
+}
+
+// position returns the position in the event log right after the given
+// resourceVersion.
+func (s *store) position(rv int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sort.Search(len(s.events), func(i int) bool { return s.events[i].rv > rv })
+}
+
+func groupResource(r *resource) schema.GroupResource {
+	return r.gv.WithResource(r.name).GroupResource()
+}
+
+func groupKind(r *resource) schema.GroupKind {
+	return r.gv.WithKind(r.kind).GroupKind()
+}
+
+func conflict(r *resource, name string) error {
+	return apierrors.NewConflict(groupResource(r), name, fmt.Errorf(
+		"the object has been modified; please apply your changes to the latest version and try again"))
+}
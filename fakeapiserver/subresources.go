@@ -0,0 +1,286 @@
+package fakeapiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// deployments/scale reads and writes just the replicas of a Deployment, in
+// the shape every scalable resource shares (that's what kubectl scale and
+// the HorizontalPodAutoscaler use).
+var scaleHandler = &handler{
+	gvk:   autoscalingv1.SchemeGroupVersion.WithKind("Scale"),
+	verbs: metav1.Verbs{"get", "update"},
+	serve: func(s *Server, w http.ResponseWriter, r *http.Request, res *resource, stored object) {
+		deployment := stored.(*appsv1.Deployment)
+
+		if r.Method == http.MethodPut {
+			scale := &autoscalingv1.Scale{}
+			if err := json.NewDecoder(r.Body).Decode(scale); err != nil {
+				writeError(w, apierrors.NewBadRequest(err.Error()))
+				return
+			}
+			if scale.Name != deployment.Name {
+				writeError(w, apierrors.NewBadRequest("the name of the object does not match the request URL"))
+				return
+			}
+
+			// The resourceVersion of the Scale is the Deployment's.
+			scaled := deployment.DeepCopy()
+			scaled.ResourceVersion = scale.ResourceVersion
+			scaled.Spec.Replicas = &scale.Spec.Replicas
+			scaled.Generation = deploymentGeneration(deployment, scaled)
+			deployment = scaled
+
+			updated, err := s.store.update(res, deployment, isDryRun(r.URL.Query()))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			deployment = updated.(*appsv1.Deployment)
+		}
+
+		writeJSON(w, http.StatusOK, deploymentScale(deployment))
+	},
+}
+
+func deploymentScale(deployment *appsv1.Deployment) *autoscalingv1.Scale {
+	scale := &autoscalingv1.Scale{
+		TypeMeta: metav1.TypeMeta{Kind: "Scale", APIVersion: autoscalingv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              deployment.Name,
+			Namespace:         deployment.Namespace,
+			UID:               deployment.UID,
+			ResourceVersion:   deployment.ResourceVersion,
+			CreationTimestamp: deployment.CreationTimestamp,
+		},
+		Status: autoscalingv1.ScaleStatus{Replicas: deployment.Status.Replicas},
+	}
+	if deployment.Spec.Replicas != nil {
+		scale.Spec.Replicas = *deployment.Spec.Replicas
+	}
+	if selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector); err == nil {
+		scale.Status.Selector = selector.String()
+	}
+	return scale
+}
+
+// pods/eviction deletes the Pod, unless that would violate its
+// PodDisruptionBudget - then it responds with 429 Too Many Requests,
+// which kubectl drain retries until the budget allows the eviction.
+var evictionHandler = &handler{
+	gvk:   policyv1.SchemeGroupVersion.WithKind("Eviction"),
+	verbs: metav1.Verbs{"create"},
+	serve: func(s *Server, w http.ResponseWriter, r *http.Request, res *resource, stored object) {
+		pod := stored.(*corev1.Pod)
+
+		eviction := &policyv1.Eviction{}
+		if err := json.NewDecoder(r.Body).Decode(eviction); err != nil {
+			writeError(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		if eviction.Name != pod.Name {
+			writeError(w, apierrors.NewBadRequest(fmt.Sprintf("name in URL does not match name in Eviction object: %s != %s", pod.Name, eviction.Name)))
+			return
+		}
+
+		opts := eviction.DeleteOptions
+		if opts == nil {
+			opts = &metav1.DeleteOptions{}
+		}
+
+		// The Pods that aren't running can't be disrupted any further.
+		switch pod.Status.Phase {
+		case corev1.PodPending, corev1.PodSucceeded, corev1.PodFailed:
+		default:
+			if err := s.checkDisruptionBudget(res, pod); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		if _, err := s.store.delete(res, pod.Namespace, pod.Name, opts.Preconditions, isDryRun(r.URL.Query()) || isDryRunDelete(opts)); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+		})
+	},
+}
+
+// checkDisruptionBudget computes what the disruption controller would put
+// into the status of the Pod's PodDisruptionBudget. The expected Pods are
+// all the Pods matching the budget's selector (the real one asks their
+// controller's scale), the healthy ones are running and ready.
+func (s *Server) checkDisruptionBudget(pods *resource, pod *corev1.Pod) error {
+	var budgets []*policyv1.PodDisruptionBudget
+	items, _ := s.store.list(podDisruptionBudgets, pod.Namespace, selector{labels: labels.Everything(), fields: fields.Everything()})
+	for _, item := range items {
+		pdb := item.(*policyv1.PodDisruptionBudget)
+		sel, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || !sel.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		budgets = append(budgets, pdb)
+	}
+
+	switch len(budgets) {
+	case 0:
+		return nil
+	case 1:
+	default:
+		return apierrors.NewInternalError(fmt.Errorf("This pod has more than one PodDisruptionBudget, which the eviction subresource does not support."))
+	}
+	pdb := budgets[0]
+
+	sel, _ := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	matching, _ := s.store.list(pods, pod.Namespace, selector{labels: sel, fields: fields.Everything()})
+	expected, healthy := len(matching), 0
+	for _, item := range matching {
+		if isHealthy(item.(*corev1.Pod)) {
+			healthy++
+		}
+	}
+
+	var desired int
+	switch {
+	case pdb.Spec.MinAvailable != nil:
+		desired, _ = intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, expected, true)
+	case pdb.Spec.MaxUnavailable != nil:
+		maxUnavailable, _ := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, expected, true)
+		desired = expected - maxUnavailable
+	}
+
+	// Evicting an unhealthy Pod doesn't make the healthy ones fewer.
+	allowed := healthy - desired
+	if (isHealthy(pod) && allowed > 0) || (!isHealthy(pod) && allowed >= 0) {
+		return nil
+	}
+
+	err := apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{
+		Type:    policyv1.DisruptionBudgetCause,
+		Message: fmt.Sprintf("The disruption budget %s needs %d healthy pods and has %d currently", pdb.Name, desired, healthy),
+	})
+	return err
+}
+
+func isHealthy(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// pods/binding is how the scheduler assigns a Pod to a Node. There is no
+// kubelet either, so the bound Pod starts running and becomes ready right
+// away.
+var bindingHandler = &handler{
+	gvk:   corev1.SchemeGroupVersion.WithKind("Binding"),
+	verbs: metav1.Verbs{"create"},
+	serve: func(s *Server, w http.ResponseWriter, r *http.Request, res *resource, stored object) {
+		pod := stored.(*corev1.Pod)
+
+		binding := &corev1.Binding{}
+		if err := json.NewDecoder(r.Body).Decode(binding); err != nil {
+			writeError(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		if binding.Name != pod.Name {
+			writeError(w, apierrors.NewBadRequest("the name of the object does not match the request URL"))
+			return
+		}
+		if binding.Target.Kind != "" && binding.Target.Kind != "Node" {
+			writeError(w, apierrors.NewBadRequest(fmt.Sprintf("must be empty or 'Node', got %q", binding.Target.Kind)))
+			return
+		}
+		if pod.Spec.NodeName != "" {
+			writeError(w, apierrors.NewConflict(groupResource(res), pod.Name, fmt.Errorf(
+				"pod %s is already assigned to node %q", pod.Name, pod.Spec.NodeName)))
+			return
+		}
+
+		now := metav1.Now()
+		pod.Spec.NodeName = binding.Target.Name
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.StartTime = &now
+		pod.Status.Conditions = nil
+		for _, typ := range []corev1.PodConditionType{corev1.PodScheduled, corev1.PodInitialized, corev1.ContainersReady, corev1.PodReady} {
+			pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{
+				Type:               typ,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: now,
+			})
+		}
+		pod.Status.ContainerStatuses = nil
+		for _, c := range pod.Spec.Containers {
+			started := true
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name:    c.Name,
+				Image:   c.Image,
+				Ready:   true,
+				Started: &started,
+				State:   corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: now}},
+			})
+		}
+
+		if _, err := s.store.update(res, pod, isDryRun(r.URL.Query())); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+		})
+	},
+}
+
+// node is the single Node of the cluster, ready to run anything.
+func node() *corev1.Node {
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    apiresource.MustParse("4"),
+		corev1.ResourceMemory: apiresource.MustParse("8Gi"),
+		corev1.ResourcePods:   apiresource.MustParse("110"),
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "fakeapiserver",
+			Labels: map[string]string{corev1.LabelHostname: "fakeapiserver"},
+		},
+		Status: corev1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity,
+			Conditions: []corev1.NodeCondition{{
+				Type:               corev1.NodeReady,
+				Status:             corev1.ConditionTrue,
+				Reason:             "KubeletReady",
+				LastHeartbeatTime:  metav1.Now(),
+				LastTransitionTime: metav1.Now(),
+			}},
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion:  "v1.30.0-fakeapiserver",
+				OperatingSystem: "linux",
+				Architecture:    "amd64",
+			},
+		},
+	}
+}
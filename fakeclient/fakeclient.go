@@ -0,0 +1,328 @@
+// Package fakeclient provides the fake clientsets the offline tests of
+// the example programs run against.
+//
+// The fakes from k8s.io/client-go/kubernetes/fake and dynamic/fake are
+// backed by an in-memory object tracker that is much dumber than a real
+// API server. In particular, it ignores metadata.generateName (every such
+// object ends up with an empty name) and never sets or checks
+// metadata.resourceVersion, so optimistic locking conflicts can't happen.
+// Nor does it know about finalizers. The clients returned by this package
+// add a reactor emulating all of it.
+package fakeclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// ListKinds maps the resources the examples work with to their list kinds.
+// The fake dynamic client needs it to serve List requests.
+var ListKinds = map[schema.GroupVersionResource]string{
+	{Group: "", Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+	{Group: "", Version: "v1", Resource: "pods"}:       "PodList",
+}
+
+// NewClientset returns a fake kubernetes.Interface pre-populated with objects.
+func NewClientset(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("*", "*", ObjectMetaReactor(client.Tracker()))
+	return client
+}
+
+// NewDynamicClient returns a fake dynamic.Interface pre-populated with
+// (unstructured) objects.
+func NewDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), ListKinds, objects...)
+	client.PrependReactor("*", "*", ObjectMetaReactor(client.Tracker()))
+	return client
+}
+
+var lastResourceVersion int64
+
+// ObjectMetaReactor handles creates, updates, and deletes the way the API
+// server does it with respect to the object metadata and the request options:
+//
+//   - a create request with an empty name and a non-empty generateName gets
+//     a random name suffix;
+//   - every stored object gets a new resourceVersion;
+//   - an update (or patch) request carrying a stale resourceVersion fails
+//     with 409 Conflict;
+//   - a delete request with unmet preconditions (UID, resourceVersion) fails
+//     with 409 Conflict, and a dry-run one doesn't delete anything;
+//   - a delete request for an object with finalizers only sets its
+//     deletionTimestamp, and the update (or patch) removing the last
+//     finalizer deletes it;
+//   - a deletecollection request deletes the objects matching its label and
+//     field (metadata.name, metadata.namespace) selectors - the default
+//     reactor ignores it altogether.
+//
+// Propagation policies and grace periods are ignored - the objects without
+// finalizers are deleted right away. All other requests are left to the
+// default tracker-backed reactor.
+func ObjectMetaReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "" {
+			return false, nil, nil
+		}
+
+		gvr := action.GetResource()
+		ns := action.GetNamespace()
+
+		switch action := action.(type) {
+		case k8stesting.CreateActionImpl:
+			// Never modify the caller's object - the real client doesn't do it either.
+			obj := action.GetObject().DeepCopyObject()
+			objMeta, err := meta.Accessor(obj)
+			if err != nil {
+				return true, nil, err
+			}
+
+			if objMeta.GetName() == "" && objMeta.GetGenerateName() != "" {
+				objMeta.SetName(objMeta.GetGenerateName() + rand.String(5))
+			}
+			if objMeta.GetName() == "" {
+				return true, nil, apierrors.NewBadRequest("name or generateName is required")
+			}
+			objMeta.SetUID(uuid.NewUUID())
+			objMeta.SetCreationTimestamp(metav1.Now())
+			objMeta.SetResourceVersion(nextResourceVersion())
+
+			if err := tracker.Create(gvr, obj, ns); err != nil {
+				return true, nil, err
+			}
+			return true, obj, nil
+
+		case k8stesting.UpdateActionImpl:
+			obj, err := update(tracker, gvr, ns, action.GetObject().DeepCopyObject())
+			return true, obj, err
+
+		case k8stesting.PatchActionImpl:
+			existing, err := tracker.Get(gvr, ns, action.GetName())
+			if err != nil {
+				return true, nil, err
+			}
+
+			obj, err := patch(existing, action.GetPatchType(), action.GetPatch())
+			if err != nil {
+				return true, nil, err
+			}
+			if obj == nil {
+				return false, nil, nil
+			}
+
+			obj, err = update(tracker, gvr, ns, obj)
+			return true, obj, err
+
+		case k8stesting.DeleteActionImpl:
+			existing, err := tracker.Get(gvr, ns, action.GetName())
+			if err != nil {
+				return true, nil, err
+			}
+			existingMeta, err := meta.Accessor(existing)
+			if err != nil {
+				return true, nil, err
+			}
+
+			if err := checkPreconditions(gvr, existingMeta, action.DeleteOptions.Preconditions); err != nil {
+				return true, nil, err
+			}
+			if isDryRun(action.DeleteOptions.DryRun) {
+				return true, existing, nil
+			}
+			if len(existingMeta.GetFinalizers()) == 0 {
+				return false, nil, nil
+			}
+
+			// It's up to the finalizers' owners to let the object go.
+			if existingMeta.GetDeletionTimestamp() != nil {
+				return true, existing, nil
+			}
+			now := metav1.Now()
+			existingMeta.SetDeletionTimestamp(&now)
+			existingMeta.SetResourceVersion(nextResourceVersion())
+			if err := tracker.Update(gvr, existing, ns); err != nil {
+				return true, nil, err
+			}
+			return true, existing, nil
+
+		case k8stesting.DeleteCollectionActionImpl:
+			kind := strings.TrimSuffix(ListKinds[gvr], "List")
+			if kind == "" {
+				return false, nil, nil
+			}
+
+			list, err := tracker.List(gvr, gvr.GroupVersion().WithKind(kind), ns)
+			if err != nil {
+				return true, nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return true, nil, err
+			}
+
+			restrictions := action.GetListRestrictions()
+			for _, item := range items {
+				itemMeta, err := meta.Accessor(item)
+				if err != nil {
+					return true, nil, err
+				}
+				if !matches(restrictions, itemMeta) {
+					continue
+				}
+				if err := tracker.Delete(gvr, itemMeta.GetNamespace(), itemMeta.GetName()); err != nil {
+					return true, nil, err
+				}
+			}
+			return true, nil, nil
+		}
+
+		return false, nil, nil
+	}
+}
+
+// update stores obj in place of the existing object, unless obj carries
+// a stale resourceVersion (an empty one means "unconditional update").
+// Updates can't change the deletionTimestamp, and the object being deleted
+// is gone with its last finalizer.
+func update(tracker k8stesting.ObjectTracker, gvr schema.GroupVersionResource, ns string, obj runtime.Object) (runtime.Object, error) {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := tracker.Get(gvr, ns, objMeta.GetName())
+	if err != nil {
+		return nil, err
+	}
+	existingMeta, err := meta.Accessor(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	if rv := objMeta.GetResourceVersion(); rv != "" && rv != existingMeta.GetResourceVersion() {
+		return nil, apierrors.NewConflict(
+			gvr.GroupResource(),
+			objMeta.GetName(),
+			fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"),
+		)
+	}
+
+	objMeta.SetUID(existingMeta.GetUID())
+	objMeta.SetCreationTimestamp(existingMeta.GetCreationTimestamp())
+	objMeta.SetDeletionTimestamp(existingMeta.GetDeletionTimestamp())
+	objMeta.SetResourceVersion(nextResourceVersion())
+
+	if objMeta.GetDeletionTimestamp() != nil && len(objMeta.GetFinalizers()) == 0 {
+		if err := tracker.Delete(gvr, ns, objMeta.GetName()); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	if err := tracker.Update(gvr, obj, ns); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// patch returns the existing object with the JSON, merge, or strategic merge
+// (typed objects only) patch applied - or nil for the patches the default
+// reactor should take care of.
+func patch(existing runtime.Object, pt types.PatchType, data []byte) (runtime.Object, error) {
+	original, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch pt {
+	case types.JSONPatchType:
+		ops, err := jsonpatch.DecodePatch(data)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		if patched, err = ops.Apply(original); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.MergePatchType:
+		if patched, err = jsonpatch.MergePatch(original, data); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	case types.StrategicMergePatchType:
+		if _, ok := existing.(*unstructured.Unstructured); ok {
+			return nil, nil
+		}
+		if patched, err = strategicpatch.StrategicMergePatch(original, data, existing); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	default:
+		return nil, nil
+	}
+
+	// Into an empty object - unmarshaling into the existing one would keep
+	// the fields the patch removes.
+	obj := reflect.New(reflect.TypeOf(existing).Elem()).Interface().(runtime.Object)
+	if err := json.Unmarshal(patched, obj); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return obj, nil
+}
+
+func checkPreconditions(gvr schema.GroupVersionResource, existing metav1.Object, preconditions *metav1.Preconditions) error {
+	if preconditions == nil {
+		return nil
+	}
+	if uid := preconditions.UID; uid != nil && *uid != existing.GetUID() {
+		return apierrors.NewConflict(gvr.GroupResource(), existing.GetName(), fmt.Errorf(
+			"Precondition failed: UID in precondition: %v, UID in object meta: %v", *uid, existing.GetUID()))
+	}
+	if rv := preconditions.ResourceVersion; rv != nil && *rv != existing.GetResourceVersion() {
+		return apierrors.NewConflict(gvr.GroupResource(), existing.GetName(), fmt.Errorf(
+			"Precondition failed: ResourceVersion in precondition: %v, ResourceVersion in object meta: %v", *rv, existing.GetResourceVersion()))
+	}
+	return nil
+}
+
+func isDryRun(dryRun []string) bool {
+	for _, v := range dryRun {
+		if v == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(restrictions k8stesting.ListRestrictions, obj metav1.Object) bool {
+	if restrictions.Labels != nil && !restrictions.Labels.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	return restrictions.Fields == nil || restrictions.Fields.Matches(fields.Set{
+		"metadata.name":      obj.GetName(),
+		"metadata.namespace": obj.GetNamespace(),
+	})
+}
+
+func nextResourceVersion() string {
+	return strconv.FormatInt(atomic.AddInt64(&lastResourceVersion, 1), 10)
+}
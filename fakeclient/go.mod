@@ -0,0 +1,9 @@
+module github.com/iximiuz/client-go-examples/fakeclient
+
+go 1.22.10
+
+require (
+	github.com/evanphx/json-patch v4.12.0+incompatible
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+)
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"k8s.io/apimachinery/pkg/fields"
 )
@@ -11,25 +13,19 @@ func main() {
 
 	// Selector matching existing field set.
 	sel := fields.SelectorFromSet(flds)
-	if sel.Matches(flds) {
-		fmt.Printf("Selector %v matched field set %v\n", sel, flds)
-	} else {
+	if !match(os.Stdout, sel, flds) {
 		panic("Selector should have matched field set")
 	}
 
 	// f==v selector.
 	sel = fields.OneTermEqualSelector("foo", "bar")
-	if sel.Matches(flds) {
-		fmt.Printf("Selector %v matched field set %v\n", sel, flds)
-	} else {
+	if !match(os.Stdout, sel, flds) {
 		panic("Selector should have matched field set")
 	}
 
 	// f!=v selector.
 	sel = fields.OneTermNotEqualSelector("qux", "abc")
-	if sel.Matches(flds) {
-		fmt.Printf("Selector %v matched field set %v\n", sel, flds)
-	} else {
+	if !match(os.Stdout, sel, flds) {
 		panic("Selector should have not matched field set")
 	}
 
@@ -38,9 +34,7 @@ func main() {
 		fields.OneTermEqualSelector("foo", "bar"),
 		fields.OneTermEqualSelector("baz", "qux"),
 	)
-	if sel.Matches(flds) {
-		fmt.Printf("Selector %v matched field set %v\n", sel, flds)
-	} else {
+	if !match(os.Stdout, sel, flds) {
 		panic("Selector should have not matched field set")
 	}
 
@@ -49,9 +43,16 @@ func main() {
 	if err != nil {
 		panic(err.Error())
 	}
-	if sel.Matches(flds) {
-		fmt.Printf("Selector %v matched field set %v\n", sel, flds)
-	} else {
+	if !match(os.Stdout, sel, flds) {
 		panic("Selector should have matched field set")
 	}
 }
+
+func match(w io.Writer, sel fields.Selector, flds fields.Set) bool {
+	if sel.Matches(flds) {
+		fmt.Fprintf(w, "Selector %v matched field set %v\n", sel, flds)
+		return true
+	}
+	fmt.Fprintf(w, "Selector %v didn't match field set %v\n", sel, flds)
+	return false
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func TestMatch(t *testing.T) {
+	parsed := func(expr string) func() (fields.Selector, error) {
+		return func() (fields.Selector, error) {
+			return fields.ParseSelector(expr)
+		}
+	}
+	built := func(sel fields.Selector) func() (fields.Selector, error) {
+		return func() (fields.Selector, error) {
+			return sel, nil
+		}
+	}
+
+	flds := fields.Set{"foo": "bar", "baz": "qux"}
+
+	var out bytes.Buffer
+	for _, tc := range []struct {
+		name     string
+		selector func() (fields.Selector, error)
+		fields   fields.Set
+	}{
+		{"from-set", built(fields.SelectorFromSet(flds)), flds},
+		{"from-empty-set", built(fields.SelectorFromSet(nil)), flds},
+		{"one-term-equal", built(fields.OneTermEqualSelector("foo", "bar")), flds},
+		{"one-term-not-equal", built(fields.OneTermNotEqualSelector("foo", "bar")), flds},
+		{"not-equal-missing-field", built(fields.OneTermNotEqualSelector("qux", "abc")), flds},
+		{"and", built(fields.AndSelectors(fields.OneTermEqualSelector("foo", "bar"), fields.OneTermEqualSelector("baz", "qux"))), flds},
+		{"everything", built(fields.Everything()), fields.Set{}},
+		{"nothing", built(fields.Nothing()), flds},
+		{"parsed-equals", parsed("foo=bar"), flds},
+		{"parsed-double-equals", parsed("foo==bar"), flds},
+		{"parsed-not-equals", parsed("foo!=bar"), flds},
+		{"parsed-and", parsed("foo=bar,baz!=bar"), flds},
+		{"parsed-empty", parsed(""), flds},
+		{"dotted-path", parsed("metadata.name=my-pod"), fields.Set{"metadata.name": "my-pod"}},
+		{"escaped-value", built(fields.OneTermEqualSelector("foo", "a,b=c")), fields.Set{"foo": "a,b=c"}},
+		{"parsed-escaped-value", parsed(`foo=a\,b\=c`), fields.Set{"foo": "a,b=c"}},
+		{"empty-value", parsed("foo="), fields.Set{"foo": ""}},
+		{"empty-value-missing-field", parsed("foo="), fields.Set{}},
+		{"unicode", parsed("ключ=значение"), fields.Set{"ключ": "значение"}},
+		{"set-based-not-supported", parsed("foo in (bar)"), flds},
+		{"unescaped-comma", parsed("foo=a,b"), flds},
+		{"invalid-escape", parsed(`foo=a\b`), flds},
+	} {
+		fmt.Fprintf(&out, "%s: ", tc.name)
+
+		sel, err := tc.selector()
+		if err != nil {
+			fmt.Fprintf(&out, "error: %v\n", err)
+			continue
+		}
+		match(&out, sel, tc.fields)
+	}
+
+	golden.Assert(t, "match", out.Bytes())
+}
@@ -0,0 +1,3 @@
+module github.com/iximiuz/client-go-examples/golden
+
+go 1.22.10
@@ -0,0 +1,42 @@
+// Package golden compares the output of the offline examples' tests with
+// the expected output kept in testdata/<name>.golden files.
+//
+// Run the tests with -update to (re-)write the files instead:
+//
+//	go test ./... -update
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/")
+
+// Assert fails the test if got differs from testdata/<name>.golden.
+func Assert(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run the tests with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run the tests with -update if it's expected)\n--- want:\n%s\n--- got:\n%s", path, want, got)
+	}
+}
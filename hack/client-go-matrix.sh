@@ -0,0 +1,107 @@
+#!/usr/bin/env bash
+#
+# Builds every go.work module against several k8s.io/* releases, runs the
+# offline tests, and prints a compatibility matrix:
+#
+#   hack/client-go-matrix.sh                          # all modules, all versions
+#   hack/client-go-matrix.sh workqueue fakeclient     # only some modules
+#   VERSIONS="0.30.8 0.31.4" hack/client-go-matrix.sh
+#
+# The tree itself is never touched. Each version gets a scratch copy of it
+# (in $WORK_DIR) with the k8s.io/* requirements of ALL the go.mod files
+# rewritten - the shared modules (bootstrap, fakeclient, ...) included,
+# since otherwise they'd pull v0.30.1 back in. Only the modules released
+# in lockstep with Kubernetes (v0.x.y) are rewritten, so klog, utils, and
+# kube-openapi are left to `go mod tidy`.
+#
+# A cell is one of:
+#
+#   pass           - builds, and the offline tests (if any) pass
+#   fail           - builds, but the tests fail
+#   compile-error  - the dependencies can't be resolved, or the code
+#                    (or the tests) doesn't compile
+#
+# The output of every cell is kept in $WORK_DIR/logs/<version>/<module>.log.
+# Exits with 1 if any cell isn't a pass.
+
+set -euo pipefail
+
+ROOT_DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+
+# Keep in sync with the README and .github/workflows/test.yml.
+VERSIONS=${VERSIONS:-"0.28.14 0.29.12 0.30.8 0.31.4"}
+WORK_DIR=${WORK_DIR:-$(mktemp -d -t client-go-matrix.XXXXXX)}
+JOBS=${JOBS:-$(getconf _NPROCESSORS_ONLN 2>/dev/null || echo 4)}
+
+# Runs a single cell - called back by xargs below.
+if [ "${1:-}" = "--cell" ]; then
+  version=$2
+  module=$3
+  log="${WORK_DIR}/logs/${version}/${module}.log"
+
+  cd "${WORK_DIR}/tree/${version}/${module}"
+  export GOWORK=off
+
+  # go test -run '^$' compiles the tests without running them.
+  if ! { go mod tidy && go build ./... && go test -count=1 -run '^$' ./...; } >"${log}" 2>&1; then
+    result=compile-error
+  elif ! go test -count=1 ./... >>"${log}" 2>&1; then
+    result=fail
+  else
+    result=pass
+  fi
+
+  echo "${result}" > "${WORK_DIR}/results/${version}/${module}"
+  echo "${version} ${module}: ${result}"
+  exit 0
+fi
+
+if [ $# -gt 0 ]; then
+  MODULES="$*"
+else
+  MODULES=$(sed -n 's|^[[:space:]]*\./\([^[:space:]]*\).*|\1|p' "${ROOT_DIR}/go.work")
+fi
+
+for version in ${VERSIONS}; do
+  tree="${WORK_DIR}/tree/${version}"
+  mkdir -p "${tree}" "${WORK_DIR}/logs/${version}" "${WORK_DIR}/results/${version}"
+
+  # Uncommitted changes are included, ignored files aren't.
+  (cd "${ROOT_DIR}" && git ls-files -co --exclude-standard | tar -cf - -T -) | tar -xf - -C "${tree}"
+
+  find "${tree}" -name go.mod | while read -r gomod; do
+    for dep in $(grep -oE '^[[:space:]]*k8s\.io/[^[:space:]]+ v0\.[1-9][0-9]*\.[0-9]+([[:space:]]|$)' "${gomod}" | awk '{ print $1 }'); do
+      go mod edit -require="${dep}@v${version}" "${gomod}"
+    done
+  done
+done
+
+echo "Testing in ${WORK_DIR}"
+
+for version in ${VERSIONS}; do
+  for module in ${MODULES}; do
+    echo "${version} ${module}"
+  done
+done | WORK_DIR="${WORK_DIR}" xargs -P "${JOBS}" -n 2 "${BASH_SOURCE[0]}" --cell
+
+echo
+printf "%-40s" "MODULE"
+for version in ${VERSIONS}; do
+  printf "%-16s" "${version}"
+done
+echo
+
+failed=0
+for module in ${MODULES}; do
+  printf "%-40s" "${module}"
+  for version in ${VERSIONS}; do
+    result=$(cat "${WORK_DIR}/results/${version}/${module}" 2>/dev/null || echo "-")
+    [ "${result}" = "pass" ] || failed=1
+    printf "%-16s" "${result}"
+  done
+  echo
+done
+
+echo
+echo "Logs: ${WORK_DIR}/logs/<version>/<module>.log"
+exit "${failed}"
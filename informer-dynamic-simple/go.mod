@@ -3,6 +3,19 @@ module github.com/iximiuz/client-go-examples/informer-dynamic-simple
 go 1.22.10
 
 require (
+	github.com/iximiuz/client-go-examples/bootstrap v0.0.0
+	github.com/iximiuz/client-go-examples/fakeclient v0.0.0
+	github.com/iximiuz/client-go-examples/lifecycle v0.0.0
+	github.com/iximiuz/client-go-examples/logging v0.0.0
 	k8s.io/apimachinery v0.30.1
 	k8s.io/client-go v0.30.1
+	k8s.io/klog/v2 v2.120.1
 )
+
+replace github.com/iximiuz/client-go-examples/bootstrap => ../bootstrap
+
+replace github.com/iximiuz/client-go-examples/fakeclient => ../fakeclient
+
+replace github.com/iximiuz/client-go-examples/lifecycle => ../lifecycle
+
+replace github.com/iximiuz/client-go-examples/logging => ../logging
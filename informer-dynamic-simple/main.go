@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"os"
-	"path"
 	"time"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -15,37 +17,47 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 var (
 	namespace         = "default"
-	label             = "informer-dynamic-simple-" + rand.String(6)
 	ConfigMapResource = schema.GroupVersionResource{
 		Group:    "",
 		Version:  "v1",
 		Resource: "configmaps",
 	}
+
+	// Tells this run's objects from the ones of the concurrent runs.
+	runID = rand.String(6)
+
+	// Long enough to see a couple of resyncs. The offline test shortens it.
+	observeFor = 10 * time.Second
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("informer-dynamic-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "informer-dynamic-simple"), config)
+	defer lc.Finish()
 
 	client, err := dynamic.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	run(ctx, client)
+}
+
+func run(ctx context.Context, client dynamic.Interface) {
 	// Create one object before initializing the informer.
-	first := createConfigMap(client)
+	first := createConfigMap(ctx, client)
 
 	// Create a shared informer factory.
 	//   - A factory is essentially a struct keeping a map (type -> informer).
@@ -55,22 +67,25 @@ func main() {
 	// When informer is requested, the factory instantiates it and keeps the
 	// the reference to it in the internal map before returning.
 	dynamicInformer := factory.ForResource(ConfigMapResource)
+	// The event handlers log with the logger from the context.
+	logger := klog.FromContext(ctx).WithValues("gvr", ConfigMapResource.String())
 	dynamicInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			cm := obj.(*unstructured.Unstructured)
-			fmt.Printf("Informer event: ConfigMap ADDED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			logger.Info("Informer event", "type", "ADDED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 		UpdateFunc: func(old, new interface{}) {
-			cm := old.(*unstructured.Unstructured)
-			fmt.Printf("Informer event: ConfigMap UPDATED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			// Resyncs come as updates with the same resourceVersion.
+			cm := new.(*unstructured.Unstructured)
+			logger.Info("Informer event", "type", "UPDATED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 		DeleteFunc: func(obj interface{}) {
 			cm := obj.(*unstructured.Unstructured)
-			fmt.Printf("Informer event: ConfigMap DELETED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			logger.Info("Informer event", "type", "DELETED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Start the informers' machinery.
@@ -97,7 +112,7 @@ func main() {
 	}
 
 	// Search for the existing ConfigMap object using the label selector.
-	selector, err := labels.Parse("example==" + label)
+	selector, err := labels.Parse("example==informer-dynamic-simple,example-run==" + runID)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -110,17 +125,20 @@ func main() {
 	}
 
 	// Create another object while watching.
-	second := createConfigMap(client)
+	second := createConfigMap(ctx, client)
 
 	// Delete config maps created by this test.
-	deleteConfigMap(client, first)
-	deleteConfigMap(client, second)
+	deleteConfigMap(ctx, client, first)
+	deleteConfigMap(ctx, client, second)
 
 	// Stay for a couple more seconds to observe resyncs.
-	time.Sleep(10 * time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(observeFor):
+	}
 }
 
-func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
+func createConfigMap(ctx context.Context, client dynamic.Interface) *unstructured.Unstructured {
 	cm := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "v1",
@@ -129,7 +147,8 @@ func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
 				"namespace":    namespace,
 				"generateName": "informer-dynamic-simple-",
 				"labels": map[string]interface{}{
-					"example": label,
+					"example":     "informer-dynamic-simple",
+					"example-run": runID,
 				},
 			},
 			"data": map[string]interface{}{
@@ -141,23 +160,27 @@ func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
 	cm, err := client.
 		Resource(ConfigMapResource).
 		Namespace(namespace).
-		Create(context.Background(), cm, metav1.CreateOptions{})
+		Create(ctx, cm, metav1.CreateOptions{})
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.Resource(ConfigMapResource).Namespace(cm.GetNamespace()).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	})
 	return cm
 }
 
-func deleteConfigMap(client dynamic.Interface, cm *unstructured.Unstructured) {
+func deleteConfigMap(ctx context.Context, client dynamic.Interface, cm *unstructured.Unstructured) {
 	err := client.
 		Resource(ConfigMapResource).
 		Namespace(cm.GetNamespace()).
-		Delete(context.Background(), cm.GetName(), metav1.DeleteOptions{})
+		Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Deleted ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRun(t *testing.T) {
+	observeFor = 100 * time.Millisecond
+
+	client := fakeclient.NewDynamicClient()
+
+	run(context.Background(), client)
+
+	var listed, watched bool
+	for _, action := range client.Actions() {
+		if action.GetResource() != ConfigMapResource {
+			continue
+		}
+		listed = listed || action.GetVerb() == "list"
+		watched = watched || action.GetVerb() == "watch"
+	}
+	if !listed || !watched {
+		t.Errorf("expected the informer to list and watch ConfigMaps, got %v", client.Actions())
+	}
+
+	list, err := client.Resource(ConfigMapResource).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMaps to be deleted, found %d", len(list.Items))
+	}
+}
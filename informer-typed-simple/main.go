@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"os"
-	"path"
 	"time"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -14,32 +16,41 @@ import (
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 var (
 	namespace = "default"
-	label     = "informer-typed-simple-" + rand.String(6)
+	// Tells this run's objects from the ones of the concurrent runs.
+	runID = rand.String(6)
+
+	// Long enough to see a couple of resyncs. The offline test shortens it.
+	observeFor = 10 * time.Second
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("informer-typed-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "informer-typed-simple"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	run(ctx, client)
+}
+
+func run(ctx context.Context, client kubernetes.Interface) {
 	// Create one object before initializing the informer.
-	first := createConfigMap(client)
+	first := createConfigMap(ctx, client)
 
 	// Create a shared informer factory.
 	//   - A factory is essentially a struct keeping a map (type -> informer).
@@ -49,22 +60,25 @@ func main() {
 	// When informer is requested, the factory instantiates it and keeps the
 	// the reference to it in the internal map before returning.
 	cmInformer := factory.Core().V1().ConfigMaps()
+	// The event handlers log with the logger from the context.
+	logger := klog.FromContext(ctx).WithValues("gvr", corev1.SchemeGroupVersion.WithResource("configmaps").String())
 	cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			cm := obj.(*corev1.ConfigMap)
-			fmt.Printf("Informer event: ConfigMap ADDED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			logger.Info("Informer event", "type", "ADDED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 		UpdateFunc: func(old, new interface{}) {
-			cm := old.(*corev1.ConfigMap)
-			fmt.Printf("Informer event: ConfigMap UPDATED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			// Resyncs come as updates with the same resourceVersion.
+			cm := new.(*corev1.ConfigMap)
+			logger.Info("Informer event", "type", "UPDATED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 		DeleteFunc: func(obj interface{}) {
 			cm := obj.(*corev1.ConfigMap)
-			fmt.Printf("Informer event: ConfigMap DELETED %s/%s\n", cm.GetNamespace(), cm.GetName())
+			logger.Info("Informer event", "type", "DELETED", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
 		},
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Start the informers' machinery.
@@ -91,7 +105,7 @@ func main() {
 	}
 
 	// Search for the existing ConfigMap object using the label selector.
-	selector, err := labels.Parse("example==" + label)
+	selector, err := labels.Parse("example==informer-typed-simple,example-run==" + runID)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -104,27 +118,30 @@ func main() {
 	}
 
 	// Create another object while watching.
-	second := createConfigMap(client)
+	second := createConfigMap(ctx, client)
 
 	// Delete config maps created by this test.
-	deleteConfigMap(client, first)
-	deleteConfigMap(client, second)
+	deleteConfigMap(ctx, client, first)
+	deleteConfigMap(ctx, client, second)
 
 	// Stay for a couple more seconds to observe resyncs.
-	time.Sleep(10 * time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(observeFor):
+	}
 }
 
-func createConfigMap(client kubernetes.Interface) *corev1.ConfigMap {
+func createConfigMap(ctx context.Context, client kubernetes.Interface) *corev1.ConfigMap {
 	cm := &corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
 	cm.Namespace = namespace
 	cm.GenerateName = "informer-typed-simple-"
-	cm.SetLabels(map[string]string{"example": label})
+	cm.SetLabels(map[string]string{"example": "informer-typed-simple", "example-run": runID})
 
 	cm, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			cm,
 			metav1.CreateOptions{},
 		)
@@ -132,16 +149,20 @@ func createConfigMap(client kubernetes.Interface) *corev1.ConfigMap {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(cm.GetNamespace()).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	})
 	return cm
 }
 
-func deleteConfigMap(client kubernetes.Interface, cm *corev1.ConfigMap) {
+func deleteConfigMap(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap) {
 	err := client.
 		CoreV1().
 		ConfigMaps(cm.GetNamespace()).
 		Delete(
-			context.Background(),
+			ctx,
 			cm.GetName(),
 			metav1.DeleteOptions{},
 		)
@@ -149,5 +170,5 @@ func deleteConfigMap(client kubernetes.Interface, cm *corev1.ConfigMap) {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Deleted ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
 }
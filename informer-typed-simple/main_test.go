@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/iximiuz/client-go-examples/cassette"
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+func TestRun(t *testing.T) {
+	observeFor = 100 * time.Millisecond
+
+	client := fakeclient.NewClientset()
+
+	run(context.Background(), client)
+
+	var listed, watched bool
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource != "configmaps" {
+			continue
+		}
+		listed = listed || action.GetVerb() == "list"
+		watched = watched || action.GetVerb() == "watch"
+	}
+	if !listed || !watched {
+		t.Errorf("expected the informer to list and watch ConfigMaps, got %v", client.Actions())
+	}
+
+	list, err := client.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMaps to be deleted, found %d", len(list.Items))
+	}
+}
+
+// TestRunCassette replays the traffic recorded against a real cluster.
+func TestRunCassette(t *testing.T) {
+	testRunCassette(t, "testdata/cassettes")
+}
+
+// TestRunFakeAPIServerCassette replays an extra fixture - the same run
+// recorded against the in-repo fakeapiserver rather than a real cluster.
+// Whatever KUBECONFIG says, it's (re-)recorded against an in-process fake.
+func TestRunFakeAPIServerCassette(t *testing.T) {
+	if cassette.Recording() {
+		server := fakeapiserver.NewServer()
+		t.Cleanup(server.Close)
+
+		kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+		if err := server.WriteKubeconfig(kubeconfig); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(clientcmd.RecommendedConfigPathEnvVar, kubeconfig)
+	}
+
+	testRunCassette(t, "testdata/cassettes/fakeapiserver")
+}
+
+func testRunCassette(t *testing.T, dir string) {
+	// The run ID ends up in the request bodies and the informer's cache,
+	// so it must not change between the recording and the replay.
+	runID = "cassette"
+	if !cassette.Recording() {
+		observeFor = 100 * time.Millisecond
+	}
+
+	var (
+		mu    sync.Mutex
+		lines []map[string]interface{}
+	)
+	logger := funcr.NewJSON(func(obj string) {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(obj), &line); err != nil {
+			t.Errorf("not a JSON log line %q: %v", obj, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, line)
+	}, funcr.Options{})
+	ctx := klog.NewContext(context.Background(), logger)
+
+	// The program itself panics if the informer doesn't see the object
+	// created before it started.
+	run(ctx, kubernetes.NewForConfigOrDie(cassette.Config(t, dir)))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The ConfigMaps run() has created (the names come from the recorded
+	// responses), and the informer events about them.
+	var created []string
+	events := map[string][]string{}
+	for _, line := range lines {
+		ref, _ := line["configMap"].(map[string]interface{})
+		name, _ := ref["name"].(string)
+		switch line["msg"] {
+		case "Created ConfigMap":
+			created = append(created, name)
+		case "Informer event":
+			// Resyncs may come in between.
+			if line["type"] != "UPDATED" {
+				events[name] = append(events[name], line["type"].(string))
+			}
+		}
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("expected 2 ConfigMaps created, got %v", created)
+	}
+	for _, name := range created {
+		if want := []string{"ADDED", "DELETED"}; !slices.Equal(events[name], want) {
+			t.Errorf("expected the informer events %v for %s, got %v", want, name, events[name])
+		}
+	}
+}
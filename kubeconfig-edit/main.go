@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// go run main.go                                    (show contexts)
+// go run main.go use-context kind-shared2
+// go run main.go set-namespace kube-system          (current context)
+// go run main.go set-namespace kube-system kind-shared1
+// go run main.go rename-context kind-shared1 shared1
+// go run main.go delete-context shared1
+// KUBECONFIG=a.yaml:b.yaml go run main.go rename-context ctx-from-b new-name
+
+const usage = `Usage: %s [flags] [command]
+
+Commands:
+  use-context <context>
+  set-namespace <namespace> [context]
+  rename-context <old> <new>
+  delete-context <context>
+
+Without a command, the contexts are listed.
+
+Flags:
+`
+
+func main() {
+	// PathOptions knows the same --kubeconfig / $KUBECONFIG / ~/.kube/config
+	// rules as kubectl and is what clientcmd.ModifyConfig() expects.
+	pathOptions := clientcmd.NewDefaultPathOptions()
+
+	flag.StringVar(&pathOptions.LoadingRules.ExplicitPath, "kubeconfig", "", "path to the kubeconfig file")
+	lockTimeout := flag.Duration("lock-timeout", 10*time.Second, "how long to wait for other kubeconfig writers")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		config, err := pathOptions.GetStartingConfig()
+		if err != nil {
+			panic(err.Error())
+		}
+		printContexts(config)
+		return
+	}
+
+	var edit func(config *api.Config) error
+
+	args := flag.Args()[1:]
+	switch cmd := flag.Arg(0); {
+	case cmd == "use-context" && len(args) == 1:
+		edit = func(config *api.Config) error {
+			return useContext(config, args[0])
+		}
+	case cmd == "set-namespace" && (len(args) == 1 || len(args) == 2):
+		edit = func(config *api.Config) error {
+			name := config.CurrentContext
+			if len(args) == 2 {
+				name = args[1]
+			}
+			return setNamespace(config, name, args[0])
+		}
+	case cmd == "rename-context" && len(args) == 2:
+		edit = func(config *api.Config) error {
+			return renameContext(config, args[0], args[1])
+		}
+	case cmd == "delete-context" && len(args) == 1:
+		edit = func(config *api.Config) error {
+			return deleteContext(config, args[0])
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := modifyConfig(pathOptions, *lockTimeout, edit); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// modifyConfig runs a read-modify-write cycle under the kubeconfig lock.
+//
+// clientcmd.ModifyConfig() does lock the files, but only for the write, and it
+// gives up right away if the lock is taken. The diff it writes is computed
+// against the config passed in, though, so if another process changes the file
+// between our read and ModifyConfig(), its change would be silently reverted.
+// That's why the same lock files are taken here for the whole cycle (waiting
+// for other writers, including kubectl), and ModifyConfig() is told not to
+// lock them again.
+func modifyConfig(pathOptions *clientcmd.PathOptions, lockTimeout time.Duration, edit func(*api.Config) error) error {
+	unlock, err := lockFiles(pathOptions.GetLoadingPrecedence(), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := pathOptions.GetStartingConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := edit(config); err != nil {
+		return err
+	}
+
+	// Every changed cluster/user/context is written back to the file it was
+	// loaded from (its LocationOfOrigin), so with KUBECONFIG=a:b an entry
+	// defined in b stays in b.
+	clientcmd.UseModifyConfigLock = false
+	return clientcmd.ModifyConfig(pathOptions, *config, true)
+}
+
+func useContext(config *api.Config, name string) error {
+	if _, ok := config.Contexts[name]; !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	config.CurrentContext = name
+	fmt.Printf("Switched to context %q\n", name)
+	return nil
+}
+
+func setNamespace(config *api.Config, name, namespace string) error {
+	context, ok := config.Contexts[name]
+	if !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	context.Namespace = namespace
+	fmt.Printf("Context %q now defaults to namespace %q\n", name, namespace)
+	return nil
+}
+
+func renameContext(config *api.Config, oldName, newName string) error {
+	context, ok := config.Contexts[oldName]
+	if !ok {
+		return fmt.Errorf("context %q not found", oldName)
+	}
+	if _, ok := config.Contexts[newName]; ok {
+		return fmt.Errorf("context %q already exists", newName)
+	}
+
+	// The copy keeps its LocationOfOrigin, hence lands in the same file.
+	config.Contexts[newName] = context
+	delete(config.Contexts, oldName)
+
+	if config.CurrentContext == oldName {
+		config.CurrentContext = newName
+	}
+	fmt.Printf("Context %q renamed to %q\n", oldName, newName)
+	return nil
+}
+
+func deleteContext(config *api.Config, name string) error {
+	if _, ok := config.Contexts[name]; !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	delete(config.Contexts, name)
+
+	if config.CurrentContext == name {
+		config.CurrentContext = ""
+		fmt.Printf("Warning: deleted the current context, current-context is unset now\n")
+	}
+	fmt.Printf("Deleted context %q\n", name)
+	return nil
+}
+
+// lockFiles uses the same <kubeconfig>.lock files as clientcmd.ModifyConfig()
+// and kubectl. Files are locked in a sorted order to avoid deadlocks.
+func lockFiles(filenames []string, timeout time.Duration) (func(), error) {
+	filenames = append([]string(nil), filenames...)
+	sort.Strings(filenames)
+
+	var locked []string
+	unlock := func() {
+		for _, lock := range locked {
+			os.Remove(lock)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, filename := range filenames {
+		lock := filename + ".lock"
+		if err := os.MkdirAll(filepath.Dir(lock), 0755); err != nil {
+			unlock()
+			return nil, err
+		}
+
+		for {
+			f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL, 0)
+			if err == nil {
+				f.Close()
+				locked = append(locked, lock)
+				break
+			}
+			if !os.IsExist(err) {
+				unlock()
+				return nil, err
+			}
+			if time.Now().After(deadline) {
+				unlock()
+				return nil, fmt.Errorf("timed out waiting for %s (remove it if no other process is editing the kubeconfig)", lock)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	return unlock, nil
+}
+
+func printContexts(config *api.Config) {
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		context := config.Contexts[name]
+		current := " "
+		if name == config.CurrentContext {
+			current = "*"
+		}
+		namespace := context.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		fmt.Printf("%s %s (namespace: %s, defined in %s)\n", current, name, namespace, context.LocationOfOrigin)
+	}
+}
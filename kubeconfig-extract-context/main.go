@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// go run main.go                            (the current context)
+// go run main.go kind-shared2
+// go run main.go -redact kind-shared2       (safe to paste into a bug report)
+// go run main.go -out shared2.yaml kind-shared2
+
+func main() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	kubeconfig := flag.String("kubeconfig", path.Join(home, ".kube/config"), "path to the kubeconfig file")
+	redact := flag.Bool("redact", false, "replace tokens, passwords, keys, and other secrets with REDACTED")
+	out := flag.String("out", "", "write the result to this file instead of stdout")
+	flag.Parse()
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeconfig},
+		&clientcmd.ConfigOverrides{},
+	).RawConfig()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if flag.NArg() > 0 {
+		config.CurrentContext = flag.Arg(0)
+	}
+
+	if err := extractContext(&config, *redact); err != nil {
+		panic(err.Error())
+	}
+
+	if *out != "" {
+		if err := clientcmd.WriteToFile(config, *out); err != nil {
+			panic(err.Error())
+		}
+		fmt.Fprintf(os.Stderr, "Wrote context %s to %s\n", config.CurrentContext, *out)
+		return
+	}
+
+	kubeconfigYAML, err := clientcmd.Write(config)
+	if err != nil {
+		panic(err.Error())
+	}
+	os.Stdout.Write(kubeconfigYAML)
+}
+
+// extractContext turns the config into the equivalent of
+// `kubectl config view --minify --flatten [--raw]` for its current context.
+func extractContext(config *api.Config, redact bool) error {
+	// Drop everything but the current context, its cluster, and its user.
+	if err := api.MinifyConfig(config); err != nil {
+		return err
+	}
+
+	// Inline certificate-authority, client-certificate, and client-key files
+	// as *-data fields. The (relative) paths are resolved using the entries'
+	// LocationOfOrigin.
+	if err := api.FlattenConfig(config); err != nil {
+		return err
+	}
+
+	// FlattenConfig() doesn't touch token files, but a standalone
+	// config can't depend on them either.
+	for _, user := range config.AuthInfos {
+		if user.TokenFile == "" {
+			continue
+		}
+		token, err := os.ReadFile(user.TokenFile)
+		if err != nil {
+			return err
+		}
+		user.Token = strings.TrimSpace(string(token))
+		user.TokenFile = ""
+	}
+
+	if redact {
+		return redactSecrets(config)
+	}
+	return nil
+}
+
+// redactSecrets hides everything that could grant access to the cluster,
+// but keeps the structure, so the config is still useful for debugging.
+func redactSecrets(config *api.Config) error {
+	// Client keys, tokens, and passwords.
+	if err := api.RedactSecrets(config); err != nil {
+		return err
+	}
+
+	for _, user := range config.AuthInfos {
+		// A client certificate isn't a secret per se, but it's an
+		// identity and rather noisy - same as `kubectl config view`.
+		if len(user.ClientCertificateData) > 0 {
+			user.ClientCertificateData = []byte("DATA+OMITTED")
+		}
+
+		// Exec plugins and auth providers often carry credentials
+		// (refresh tokens, client secrets, API keys) in their settings.
+		if user.Exec != nil {
+			for i := range user.Exec.Env {
+				user.Exec.Env[i].Value = "REDACTED"
+			}
+			redactArgs(user.Exec.Args)
+		}
+		if user.AuthProvider != nil {
+			for key := range user.AuthProvider.Config {
+				user.AuthProvider.Config[key] = "REDACTED"
+			}
+		}
+	}
+
+	return nil
+}
+
+// redactArgs masks the values of the exec plugin arguments that look like
+// credentials - --token=..., --client-secret ..., --password=..., etc.
+// The other arguments (subcommands, cluster names, regions) stay.
+func redactArgs(args []string) {
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+
+		name, _, hasValue := strings.Cut(args[i], "=")
+		if !isSecretFlag(name) {
+			continue
+		}
+		if hasValue {
+			args[i] = name + "=REDACTED"
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			// The value is the next argument.
+			i++
+			args[i] = "REDACTED"
+		}
+	}
+}
+
+func isSecretFlag(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"token", "secret", "password"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+module github.com/iximiuz/client-go-examples/kubeconfig-from-serviceaccount
+
+go 1.22.10
+
+require (
+	k8s.io/api v0.30.1
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+)
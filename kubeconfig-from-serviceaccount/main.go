@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// go run main.go
+// go run main.go -name ci-bot -ttl 30m -out ci-bot.kubeconfig
+// go run main.go -cleanup
+// go run main.go -audience https://kubernetes.default.svc.cluster.local
+
+func main() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	namespace := flag.String("namespace", "default", "namespace of the ServiceAccount")
+	name := flag.String("name", "kubeconfig-from-serviceaccount", "name of the ServiceAccount")
+	audience := flag.String("audience", "", "token audience (defaults to the API server's own audience)")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime (the API server enforces a 10m minimum)")
+	out := flag.String("out", "", "write the generated kubeconfig to this file instead of stdout")
+	cleanup := flag.Bool("cleanup", false, "delete the ServiceAccount and its RBAC objects at exit (revokes the token, so the generated kubeconfig stops working)")
+	flag.Parse()
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path.Join(home, ".kube/config")},
+		&clientcmd.ConfigOverrides{},
+	)
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	ctx := context.Background()
+
+	// 1. Create a ServiceAccount allowed to read ConfigMaps and nothing else.
+	// The ServiceAccount is kept by default - deleting it revokes the bound
+	// token, and the printed kubeconfig would be useless.
+	objects, err := createServiceAccount(ctx, client, *namespace, *name)
+	if err != nil {
+		// No token has been handed out yet - clean up after a half-way failure.
+		objects.delete(ctx, *namespace, *name)
+		panic(err.Error())
+	}
+	if *cleanup {
+		defer objects.delete(ctx, *namespace, *name)
+	}
+
+	// 2. Request a short-lived token bound to the ServiceAccount.
+	var audiences []string
+	if *audience != "" {
+		audiences = []string{*audience}
+	}
+	token, err := requestToken(ctx, client, *namespace, *name, audiences, *ttl)
+	if err != nil {
+		panic(err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "Obtained token expiring at %s\n", token.Status.ExpirationTimestamp.Format(time.RFC3339))
+
+	// 3. Turn it into a self-contained kubeconfig.
+	clusterName := "cluster"
+	if current, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+		clusterName = current.Cluster
+	}
+	kubeconfig, err := buildKubeconfig(config, clusterName, *namespace, *name, token.Status.Token)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if *out != "" {
+		if err := clientcmd.WriteToFile(*kubeconfig, *out); err != nil {
+			panic(err.Error())
+		}
+		fmt.Fprintf(os.Stderr, "Wrote kubeconfig to %s\n", *out)
+	} else {
+		kubeconfigYAML, err := clientcmd.Write(*kubeconfig)
+		if err != nil {
+			panic(err.Error())
+		}
+		os.Stdout.Write(kubeconfigYAML)
+	}
+
+	// 4. Make sure the generated kubeconfig works and is actually scoped.
+	if *audience == "" {
+		if err := checkScope(ctx, kubeconfig, *namespace); err != nil {
+			panic(err.Error())
+		}
+		fmt.Fprintln(os.Stderr, "Verified the kubeconfig can list ConfigMaps but not Secrets")
+	}
+}
+
+// created is the objects createServiceAccount has actually created - the
+// ones that existed before the run (e.g., left by a previous run, or
+// belonging to someone else) are never deleted.
+type created []createdObject
+
+type createdObject struct {
+	kind   string
+	delete func(ctx context.Context, name string, opts metav1.DeleteOptions) error
+}
+
+// delete removes the objects in the reverse order of their creation.
+func (c created) delete(ctx context.Context, namespace, name string) {
+	for i := len(c) - 1; i >= 0; i-- {
+		if err := c[i].delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "Failed to delete %s %s/%s: %v\n", c[i].kind, namespace, name, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Deleted %s %s/%s\n", c[i].kind, namespace, name)
+	}
+}
+
+// createServiceAccount creates the ServiceAccount, its Role, and the
+// RoleBinding, reusing the ones that already exist. The objects created
+// before a failure are returned, too.
+func createServiceAccount(ctx context.Context, client kubernetes.Interface, namespace, name string) (created, error) {
+	var objects created
+	track := func(kind string, err error, delete func(context.Context, string, metav1.DeleteOptions) error) error {
+		switch {
+		case err == nil:
+			objects = append(objects, createdObject{kind: kind, delete: delete})
+			fmt.Fprintf(os.Stderr, "Created %s %s/%s\n", kind, namespace, name)
+		case errors.IsAlreadyExists(err):
+			fmt.Fprintf(os.Stderr, "%s %s/%s already exists, reusing it (it won't be deleted)\n", kind, namespace, name)
+		default:
+			return err
+		}
+		return nil
+	}
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+	_, err := client.CoreV1().ServiceAccounts(namespace).Create(ctx, sa, metav1.CreateOptions{})
+	if err := track("ServiceAccount", err, client.CoreV1().ServiceAccounts(namespace).Delete); err != nil {
+		return objects, err
+	}
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "list", "watch"},
+		}},
+	}
+	_, err = client.RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{})
+	if err := track("Role", err, client.RbacV1().Roles(namespace).Delete); err != nil {
+		return objects, err
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      name,
+			Namespace: namespace,
+		}},
+	}
+	_, err = client.RbacV1().RoleBindings(namespace).Create(ctx, binding, metav1.CreateOptions{})
+	if err := track("RoleBinding", err, client.RbacV1().RoleBindings(namespace).Delete); err != nil {
+		return objects, err
+	}
+
+	return objects, nil
+}
+
+// requestToken uses the TokenRequest API (the serviceaccounts/token subresource).
+// Unlike the legacy token Secrets, such tokens are short-lived and get
+// invalidated as soon as the ServiceAccount is deleted.
+func requestToken(
+	ctx context.Context,
+	client kubernetes.Interface,
+	namespace, name string,
+	audiences []string,
+	ttl time.Duration,
+) (*authenticationv1.TokenRequest, error) {
+	expirationSeconds := int64(ttl.Seconds())
+	req := &authenticationv1.TokenRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+
+	token, err := client.
+		CoreV1().
+		ServiceAccounts(namespace).
+		CreateToken(ctx, name, req, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if token.Status.Token == "" {
+		return nil, fmt.Errorf("API server returned an empty token for ServiceAccount %s/%s", namespace, name)
+	}
+	return token, nil
+}
+
+// buildKubeconfig produces a kubeconfig that doesn't depend on any local
+// files - the CA is embedded even if the original config referenced a file.
+func buildKubeconfig(config *rest.Config, clusterName, namespace, name, token string) (*api.Config, error) {
+	caData := config.TLSClientConfig.CAData
+	if len(caData) == 0 && config.TLSClientConfig.CAFile != "" {
+		var err error
+		if caData, err = os.ReadFile(config.TLSClientConfig.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	contextName := fmt.Sprintf("%s@%s", name, clusterName)
+
+	kubeconfig := api.NewConfig()
+	kubeconfig.Clusters[clusterName] = &api.Cluster{
+		Server:                   config.Host,
+		CertificateAuthorityData: caData,
+		TLSServerName:            config.TLSClientConfig.ServerName,
+		InsecureSkipTLSVerify:    config.TLSClientConfig.Insecure,
+	}
+	kubeconfig.AuthInfos[name] = &api.AuthInfo{
+		Token: token,
+	}
+	kubeconfig.Contexts[contextName] = &api.Context{
+		Cluster:   clusterName,
+		AuthInfo:  name,
+		Namespace: namespace,
+	}
+	kubeconfig.CurrentContext = contextName
+
+	return kubeconfig, nil
+}
+
+func checkScope(ctx context.Context, kubeconfig *api.Config, namespace string) error {
+	config, err := clientcmd.NewDefaultClientConfig(*kubeconfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// The RBAC authorizer learns about the new RoleBinding asynchronously.
+	err = wait.PollUntilContextTimeout(ctx, 200*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		_, err := client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing ConfigMaps should have been allowed: %w", err)
+	}
+
+	_, err = client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if !errors.IsForbidden(err) {
+		return fmt.Errorf("listing Secrets should have been forbidden, got: %v", err)
+	}
+
+	return nil
+}
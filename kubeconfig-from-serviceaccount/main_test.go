@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const fakeCA = "-----BEGIN CERTIFICATE-----\nfake\n-----END CERTIFICATE-----\n"
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	// The fake clientset doesn't issue tokens - the reactor plays the
+	// API server's TokenRequest endpoint.
+	var requested *authenticationv1.TokenRequest
+	now := time.Now()
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+
+		requested = action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+		token := requested.DeepCopy()
+		token.Status = authenticationv1.TokenRequestStatus{
+			Token:               "fake-token",
+			ExpirationTimestamp: metav1.NewTime(now.Add(time.Duration(*requested.Spec.ExpirationSeconds) * time.Second)),
+		}
+		return true, token, nil
+	})
+
+	objects, err := createServiceAccount(ctx, client, "default", "ci-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 created objects, got %d", len(objects))
+	}
+
+	token, err := requestToken(ctx, client, "default", "ci-bot", []string{"https://example.com"}, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requested == nil {
+		t.Fatal("expected a TokenRequest")
+	}
+	if requested.Name != "ci-bot" || requested.Namespace != "default" {
+		t.Errorf("expected a TokenRequest for default/ci-bot, got %s/%s", requested.Namespace, requested.Name)
+	}
+	if len(requested.Spec.Audiences) != 1 || requested.Spec.Audiences[0] != "https://example.com" {
+		t.Errorf("expected the requested audience, got %v", requested.Spec.Audiences)
+	}
+	if *requested.Spec.ExpirationSeconds != 1800 {
+		t.Errorf("expected the token to expire in 1800s, got %d", *requested.Spec.ExpirationSeconds)
+	}
+	if want := now.Add(30 * time.Minute); !token.Status.ExpirationTimestamp.Time.Equal(want) {
+		t.Errorf("expected the token to expire at %v, got %v", want, token.Status.ExpirationTimestamp)
+	}
+
+	// The CA referenced by a file ends up embedded.
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, []byte(fakeCA), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := &rest.Config{
+		Host:            "https://127.0.0.1:6443",
+		TLSClientConfig: rest.TLSClientConfig{CAFile: caFile},
+	}
+	kubeconfig, err := buildKubeconfig(config, "kind-kind", "default", "ci-bot", token.Status.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What the users get - the written out YAML.
+	kubeconfigYAML, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := clientcmd.Load(kubeconfigYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.CurrentContext != "ci-bot@kind-kind" {
+		t.Errorf("expected the current context ci-bot@kind-kind, got %q", loaded.CurrentContext)
+	}
+	kubeContext, ok := loaded.Contexts["ci-bot@kind-kind"]
+	if !ok {
+		t.Fatalf("expected the context ci-bot@kind-kind, got %v", loaded.Contexts)
+	}
+	if kubeContext.Cluster != "kind-kind" || kubeContext.AuthInfo != "ci-bot" || kubeContext.Namespace != "default" {
+		t.Errorf("expected the context to point to kind-kind, ci-bot, and default, got %+v", kubeContext)
+	}
+
+	cluster, ok := loaded.Clusters["kind-kind"]
+	if !ok {
+		t.Fatalf("expected the cluster kind-kind, got %v", loaded.Clusters)
+	}
+	if cluster.Server != "https://127.0.0.1:6443" {
+		t.Errorf("expected the server to be copied over, got %q", cluster.Server)
+	}
+	if !bytes.Equal(cluster.CertificateAuthorityData, []byte(fakeCA)) || cluster.CertificateAuthority != "" {
+		t.Errorf("expected the CA to be embedded, got %q (file %q)", cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	}
+
+	user, ok := loaded.AuthInfos["ci-bot"]
+	if !ok {
+		t.Fatalf("expected the user ci-bot, got %v", loaded.AuthInfos)
+	}
+	if user.Token != "fake-token" {
+		t.Errorf("expected the issued token, got %q", user.Token)
+	}
+
+	objects.delete(ctx, "default", "ci-bot")
+
+	if _, err := client.CoreV1().ServiceAccounts("default").Get(ctx, "ci-bot", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the ServiceAccount to be deleted, got %v", err)
+	}
+	if _, err := client.RbacV1().Roles("default").Get(ctx, "ci-bot", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the Role to be deleted, got %v", err)
+	}
+	if _, err := client.RbacV1().RoleBindings("default").Get(ctx, "ci-bot", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the RoleBinding to be deleted, got %v", err)
+	}
+}
+
+func TestCreateServiceAccountKeepsExisting(t *testing.T) {
+	ctx := context.Background()
+
+	// Someone else's ServiceAccount with the same name.
+	client := fake.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-bot", Namespace: "default"},
+	})
+
+	objects, err := createServiceAccount(ctx, client, "default", "ci-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("expected only the Role and the RoleBinding to be created, got %d objects", len(objects))
+	}
+
+	objects.delete(ctx, "default", "ci-bot")
+
+	if _, err := client.CoreV1().ServiceAccounts("default").Get(ctx, "ci-bot", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the existing ServiceAccount to be kept, got %v", err)
+	}
+	if _, err := client.RbacV1().Roles("default").Get(ctx, "ci-bot", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the Role to be deleted, got %v", err)
+	}
+	if _, err := client.RbacV1().RoleBindings("default").Get(ctx, "ci-bot", metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the RoleBinding to be deleted, got %v", err)
+	}
+}
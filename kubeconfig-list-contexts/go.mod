@@ -4,4 +4,5 @@ go 1.22.10
 
 require (
 	k8s.io/client-go v0.30.1
+	sigs.k8s.io/yaml v1.4.0
 )
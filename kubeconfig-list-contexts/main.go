@@ -1,28 +1,202 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
 )
 
+// go run main.go
+// go run main.go -o json
+// go run main.go -o yaml --kubeconfig /path/to/merged/config
+
+type ContextInfo struct {
+	Name      string   `json:"name"`
+	Current   bool     `json:"current"`
+	Cluster   string   `json:"cluster"`
+	Server    string   `json:"server,omitempty"`
+	User      string   `json:"user"`
+	Namespace string   `json:"namespace"`
+	Auth      []string `json:"auth"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
 func main() {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
 
+	kubeconfig := flag.String("kubeconfig", path.Join(home, ".kube/config"), "path to the kubeconfig file")
+	output := flag.String("o", "table", "output format: table|json|yaml")
+	flag.Parse()
+
 	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path.Join(home, ".kube/config")},
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeconfig},
 		&clientcmd.ConfigOverrides{},
 	).RawConfig()
 	if err != nil {
 		panic(err.Error())
 	}
 
+	inventory := listContexts(&config)
+
+	switch *output {
+	case "table":
+		printTable(os.Stdout, inventory)
+	case "json":
+		printJSON(os.Stdout, inventory)
+	case "yaml":
+		printYAML(os.Stdout, inventory)
+	default:
+		panic(fmt.Sprintf("unknown output format %q", *output))
+	}
+
+	if config.CurrentContext != "" && config.Contexts[config.CurrentContext] == nil {
+		fmt.Fprintf(os.Stderr, "current-context %q is not defined\n", config.CurrentContext)
+		os.Exit(1)
+	}
+	for _, ctx := range inventory {
+		if len(ctx.Problems) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// listContexts collects a (name-sorted) description of every context
+// in the config, including the dangling references and missing files
+// that usually show up after merging kubeconfigs by hand.
+func listContexts(config *api.Config) []ContextInfo {
+	names := make([]string, 0, len(config.Contexts))
 	for name := range config.Contexts {
-		fmt.Printf("Found context %s\n", name)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inventory := make([]ContextInfo, 0, len(names))
+	for _, name := range names {
+		ctx := config.Contexts[name]
+		info := ContextInfo{
+			Name:      name,
+			Current:   name == config.CurrentContext,
+			Cluster:   ctx.Cluster,
+			User:      ctx.AuthInfo,
+			Namespace: ctx.Namespace,
+		}
+		if info.Namespace == "" {
+			info.Namespace = "default"
+		}
+
+		if cluster, ok := config.Clusters[ctx.Cluster]; ok {
+			info.Server = cluster.Server
+			info.Problems = append(info.Problems, checkFile("certificate-authority", cluster.CertificateAuthority)...)
+		} else {
+			info.Problems = append(info.Problems, fmt.Sprintf("cluster %q not found", ctx.Cluster))
+		}
+
+		if user, ok := config.AuthInfos[ctx.AuthInfo]; ok {
+			info.Auth = authMechanisms(user)
+			info.Problems = append(info.Problems, checkFile("client-certificate", user.ClientCertificate)...)
+			info.Problems = append(info.Problems, checkFile("client-key", user.ClientKey)...)
+			info.Problems = append(info.Problems, checkFile("token-file", user.TokenFile)...)
+		} else {
+			info.Problems = append(info.Problems, fmt.Sprintf("user %q not found", ctx.AuthInfo))
+		}
+
+		inventory = append(inventory, info)
+	}
+
+	return inventory
+}
+
+// authMechanisms tells how the user authenticates. A single AuthInfo can
+// (mis)configure several mechanisms at once, so all of them are reported.
+func authMechanisms(user *api.AuthInfo) []string {
+	var mechanisms []string
+	if user.ClientCertificate != "" || len(user.ClientCertificateData) > 0 {
+		mechanisms = append(mechanisms, "client-cert")
+	}
+	if user.Token != "" || user.TokenFile != "" {
+		mechanisms = append(mechanisms, "token")
+	}
+	if user.Username != "" || user.Password != "" {
+		mechanisms = append(mechanisms, "basic")
+	}
+	if user.Exec != nil {
+		mechanisms = append(mechanisms, "exec:"+path.Base(user.Exec.Command))
+	}
+	if user.AuthProvider != nil {
+		mechanisms = append(mechanisms, "auth-provider:"+user.AuthProvider.Name)
+	}
+	if len(mechanisms) == 0 {
+		mechanisms = append(mechanisms, "none")
+	}
+	return mechanisms
+}
+
+// checkFile reports a problem if the kubeconfig refers to a file that
+// doesn't exist. The loading rules have already resolved relative paths
+// against the kubeconfig's own location.
+func checkFile(field, filename string) []string {
+	if filename == "" {
+		return nil
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return []string{fmt.Sprintf("%s file %s: %v", field, filename, errorReason(err))}
+	}
+	return nil
+}
+
+func errorReason(err error) string {
+	if os.IsNotExist(err) {
+		return "not found"
+	}
+	return err.Error()
+}
+
+func printTable(w io.Writer, inventory []ContextInfo) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CURRENT\tNAME\tCLUSTER\tSERVER\tUSER\tNAMESPACE\tAUTH\tPROBLEMS")
+	for _, ctx := range inventory {
+		current := ""
+		if ctx.Current {
+			current = "*"
+		}
+		problems := strings.Join(ctx.Problems, "; ")
+		if problems == "" {
+			problems = "-"
+		}
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			current, ctx.Name, ctx.Cluster, ctx.Server, ctx.User, ctx.Namespace,
+			strings.Join(ctx.Auth, ","), problems,
+		)
+	}
+	tw.Flush()
+}
+
+func printJSON(w io.Writer, inventory []ContextInfo) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(inventory); err != nil {
+		panic(err.Error())
+	}
+}
+
+func printYAML(w io.Writer, inventory []ContextInfo) {
+	out, err := yaml.Marshal(inventory)
+	if err != nil {
+		panic(err.Error())
 	}
+	w.Write(out)
 }
@@ -0,0 +1,7 @@
+module github.com/iximiuz/client-go-examples/kubeconfig-merge
+
+go 1.22.10
+
+require (
+	k8s.io/client-go v0.30.1
+)
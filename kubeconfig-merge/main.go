@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// go run main.go                                  (uses $KUBECONFIG or ~/.kube/config)
+// go run main.go ~/.kube/config ~/.kube/other     (the first file has the highest priority)
+// go run main.go -out merged.yaml a.yaml b.yaml
+
+func main() {
+	out := flag.String("out", "", "write the merged and flattened kubeconfig to this file")
+	flag.Parse()
+
+	// The default loading rules already know how to split $KUBECONFIG
+	// into the Precedence list (and fall back to ~/.kube/config).
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if flag.NArg() > 0 {
+		rules.Precedence = flag.Args()
+	}
+
+	fmt.Println("Precedence (highest first):")
+	for i, filename := range rules.Precedence {
+		fmt.Printf("  %d. %s\n", i+1, filename)
+	}
+	fmt.Println()
+
+	// Merge the files exactly the way every client-go program does it.
+	merged, err := rules.Load()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// Load every file separately to find out what got shadowed.
+	files := map[string]*api.Config{}
+	for _, filename := range rules.Precedence {
+		config, err := clientcmd.LoadFromFile(filename)
+		if os.IsNotExist(err) {
+			fmt.Printf("Skipping missing file %s\n", filename)
+			continue
+		}
+		if err != nil {
+			panic(err.Error())
+		}
+		if err := clientcmd.ResolveLocalPaths(config); err != nil {
+			panic(err.Error())
+		}
+		files[filename] = config
+	}
+
+	// Map entries (clusters, users, contexts) are merged key by key and the
+	// first file that defines a key wins. Every loaded entry remembers the
+	// file it came from in LocationOfOrigin, so the winner can be read
+	// right from the merged config.
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tWINNER\tSHADOWED\tCONFLICT")
+
+	conflicts := 0
+	report := func(kind string, name string, winner string, entryIn func(*api.Config) (interface{}, bool)) {
+		winnerEntry, _ := entryIn(files[winner])
+		shadowed := false
+		for _, filename := range rules.Precedence {
+			if filename == winner || files[filename] == nil {
+				continue
+			}
+			entry, ok := entryIn(files[filename])
+			if !ok {
+				continue
+			}
+			shadowed = true
+			conflict := "no"
+			if !reflect.DeepEqual(entry, winnerEntry) {
+				conflict = "yes"
+				conflicts++
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", kind, name, winner, filename, conflict)
+		}
+		if !shadowed {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t-\t-\n", kind, name, winner)
+		}
+	}
+
+	for _, name := range sortedKeys(merged.Clusters) {
+		report("cluster", name, merged.Clusters[name].LocationOfOrigin, func(c *api.Config) (interface{}, bool) {
+			cluster, ok := c.Clusters[name]
+			if ok {
+				cluster = cluster.DeepCopy()
+				cluster.LocationOfOrigin = ""
+			}
+			return cluster, ok
+		})
+	}
+	for _, name := range sortedKeys(merged.AuthInfos) {
+		report("user", name, merged.AuthInfos[name].LocationOfOrigin, func(c *api.Config) (interface{}, bool) {
+			user, ok := c.AuthInfos[name]
+			if ok {
+				user = user.DeepCopy()
+				user.LocationOfOrigin = ""
+			}
+			return user, ok
+		})
+	}
+	for _, name := range sortedKeys(merged.Contexts) {
+		report("context", name, merged.Contexts[name].LocationOfOrigin, func(c *api.Config) (interface{}, bool) {
+			context, ok := c.Contexts[name]
+			if ok {
+				context = context.DeepCopy()
+				context.LocationOfOrigin = ""
+			}
+			return context, ok
+		})
+	}
+
+	// Non-map fields (current-context, preferences) are taken from
+	// the first file that sets them, too.
+	if winner := currentContextOrigin(rules.Precedence, files); winner != "" {
+		report("current-context", merged.CurrentContext, winner, func(c *api.Config) (interface{}, bool) {
+			return c.CurrentContext, c.CurrentContext != ""
+		})
+	}
+	tw.Flush()
+
+	fmt.Printf("\nFound %d conflicting entries\n", conflicts)
+
+	if *out == "" {
+		return
+	}
+
+	// File references are relative to the file that defined them, so
+	// the merged config is flattened to stay valid at any location.
+	if err := api.FlattenConfig(merged); err != nil {
+		panic(err.Error())
+	}
+	if err := clientcmd.WriteToFile(*merged, *out); err != nil {
+		panic(err.Error())
+	}
+	fmt.Printf("Wrote merged kubeconfig to %s\n", *out)
+}
+
+func currentContextOrigin(precedence []string, files map[string]*api.Config) string {
+	for _, filename := range precedence {
+		if config := files[filename]; config != nil && config.CurrentContext != "" {
+			return filename
+		}
+	}
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,5 +3,6 @@ module github.com/iximiuz/client-go-examples/kubeconfig-overriden-context
 go 1.22.10
 
 require (
+	k8s.io/apimachinery v0.30.1
 	k8s.io/client-go v0.30.1
 )
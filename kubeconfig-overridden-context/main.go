@@ -1,37 +1,244 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
 )
 
+// go run main.go kind-cluster2        (a single context)
+// go run main.go -all                 (every context from the kubeconfig)
+// go run main.go -timeout 2s 'kind-*' (contexts matching a glob)
+
+type ProbeResult struct {
+	Context string
+	Server  string
+	Version string
+	Latency time.Duration
+	Err     error
+}
+
+func (r ProbeResult) Reachable() bool {
+	return r.Err == nil
+}
+
 func main() {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
 	}
 
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path.Join(home, ".kube/config")},
-		&clientcmd.ConfigOverrides{CurrentContext: os.Args[1]},
-	).ClientConfig()
+	all := flag.Bool("all", false, "probe all contexts from the kubeconfig")
+	timeout := flag.Duration("timeout", 5*time.Second, "per-context timeout")
+	flag.Parse()
+
+	kubeconfig := path.Join(home, ".kube/config")
+
+	if !*all && flag.NArg() == 1 && !isGlob(flag.Arg(0)) {
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: flag.Arg(0)},
+		).ClientConfig()
+		if err != nil {
+			panic(err.Error())
+		}
+
+		client, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		ver, err := client.ServerVersion()
+		if err != nil {
+			panic(err.Error())
+		}
+
+		fmt.Println(ver.String())
+		return
+	}
+
+	rawConfig, err := (&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}).Load()
 	if err != nil {
 		panic(err.Error())
 	}
 
-	client, err := discovery.NewDiscoveryClientForConfig(config)
+	patterns := flag.Args()
+	if *all {
+		patterns = []string{"*"}
+	}
+
+	contexts, err := selectContexts(rawConfig, patterns)
 	if err != nil {
 		panic(err.Error())
 	}
+	if len(contexts) == 0 {
+		panic(fmt.Sprintf("no contexts match %v", patterns))
+	}
+
+	results := probeContexts(context.Background(), rawConfig, contexts, *timeout)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTEXT\tSERVER\tREACHABLE\tVERSION\tLATENCY\tERROR")
+	unreachable := 0
+	for _, r := range results {
+		ver, errClass := r.Version, "-"
+		if !r.Reachable() {
+			unreachable++
+			ver, errClass = "-", classifyError(r.Err)
+		}
+		fmt.Fprintf(
+			tw, "%s\t%s\t%t\t%s\t%s\t%s\n",
+			r.Context, r.Server, r.Reachable(), ver, r.Latency.Round(time.Millisecond), errClass,
+		)
+	}
+	tw.Flush()
+
+	for _, r := range results {
+		if !r.Reachable() {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Context, r.Err)
+		}
+	}
+
+	if unreachable > 0 {
+		os.Exit(1)
+	}
+}
+
+// selectContexts returns the (sorted) names of the contexts matching
+// any of the given glob patterns.
+func selectContexts(config *api.Config, patterns []string) ([]string, error) {
+	var names []string
+	for name := range config.Contexts {
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// probeContexts asks every context's API server for its version concurrently.
+// A slow or unreachable cluster doesn't affect the others - every probe has
+// its own timeout, and errors are collected instead of aborting the run.
+// The results are returned in the same order as the contexts.
+func probeContexts(ctx context.Context, config *api.Config, contexts []string, timeout time.Duration) []ProbeResult {
+	results := make([]ProbeResult, len(contexts))
+
+	var wg sync.WaitGroup
+	for i, name := range contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probeContext(ctx, config, name, timeout)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
 
-	ver, err := client.ServerVersion()
+func probeContext(ctx context.Context, config *api.Config, name string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Context: name}
+
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(
+		*config, name, &clientcmd.ConfigOverrides{}, nil,
+	).ClientConfig()
 	if err != nil {
-		panic(err.Error())
+		result.Err = err
+		return result
 	}
+	result.Server = restConfig.Host
+
+	// rest.Config.Timeout limits every HTTP request made by the client,
+	// the context additionally bounds the whole probe.
+	restConfig.Timeout = timeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// Same request as client.ServerVersion(), but bound to the context.
+	start := time.Now()
+	body, err := client.RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var ver version.Info
+	if err := json.Unmarshal(body, &ver); err != nil {
+		result.Err = fmt.Errorf("unable to parse the server version: %w", err)
+		return result
+	}
+	result.Version = ver.GitVersion
+
+	return result
+}
+
+// classifyError turns a probe error into a short, script-friendly reason.
+func classifyError(err error) string {
+	var (
+		netErr       net.Error
+		dnsErr       *net.DNSError
+		unknownAuth  x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certErr      x509.CertificateInvalidError
+		tlsHeaderErr tls.RecordHeaderError
+	)
+
+	switch {
+	case apierrors.IsUnauthorized(err):
+		return "unauthorized"
+	case apierrors.IsForbidden(err):
+		return "forbidden"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &unknownAuth), errors.As(err, &hostnameErr),
+		errors.As(err, &certErr), errors.As(err, &tlsHeaderErr):
+		return "tls"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection-refused"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case clientcmd.IsConfigurationInvalid(err) || clientcmd.IsEmptyConfig(err):
+		return "config"
+	default:
+		return "other"
+	}
+}
 
-	fmt.Println(ver.String())
+func isGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestProbeContexts(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major":"1","minor":"30","gitVersion":"v1.30.1"}`))
+	}))
+	defer healthy.Close()
+
+	// Answers only when the client gives up (or the test is over).
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer slow.Close()
+	defer close(done)
+
+	// A port nobody listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + l.Addr().String()
+	l.Close()
+
+	config := api.NewConfig()
+	for name, server := range map[string]string{
+		"healthy": healthy.URL,
+		"slow":    slow.URL,
+		"closed":  closed,
+	} {
+		config.Clusters[name] = &api.Cluster{Server: server}
+		config.AuthInfos[name] = &api.AuthInfo{Token: "token"}
+		config.Contexts[name] = &api.Context{Cluster: name, AuthInfo: name}
+	}
+	// Points to nowhere - doesn't go through clientcmd's validation.
+	config.Contexts["broken"] = &api.Context{Cluster: "missing", AuthInfo: "missing"}
+
+	contexts, err := selectContexts(config, []string{"*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const timeout = 500 * time.Millisecond
+	start := time.Now()
+	results := probeContexts(context.Background(), config, contexts, timeout)
+	elapsed := time.Since(start)
+
+	// The probes run concurrently, so only the slow one takes the timeout.
+	if elapsed > 2*timeout {
+		t.Errorf("expected the probes to take about %v, took %v", timeout, elapsed)
+	}
+
+	if len(results) != len(contexts) {
+		t.Fatalf("expected %d results, got %d", len(contexts), len(results))
+	}
+	for i, r := range results {
+		if r.Context != contexts[i] {
+			t.Errorf("expected the results in the order of the contexts, got %s at %d", r.Context, i)
+		}
+	}
+
+	for _, tc := range []struct {
+		context   string
+		server    string
+		reachable bool
+		version   string
+		errClass  string
+	}{
+		{"broken", "", false, "", "config"},
+		{"closed", closed, false, "", "connection-refused"},
+		{"healthy", healthy.URL, true, "v1.30.1", ""},
+		{"slow", slow.URL, false, "", "timeout"},
+	} {
+		var r ProbeResult
+		for _, result := range results {
+			if result.Context == tc.context {
+				r = result
+			}
+		}
+
+		if r.Server != tc.server {
+			t.Errorf("%s: expected server %q, got %q", tc.context, tc.server, r.Server)
+		}
+		if r.Reachable() != tc.reachable {
+			t.Errorf("%s: expected reachable=%t, got error %v", tc.context, tc.reachable, r.Err)
+		}
+		if r.Version != tc.version {
+			t.Errorf("%s: expected version %q, got %q", tc.context, tc.version, r.Version)
+		}
+		if !r.Reachable() {
+			if got := classifyError(r.Err); got != tc.errClass {
+				t.Errorf("%s: expected error class %q, got %q (%v)", tc.context, tc.errClass, got, r.Err)
+			}
+		}
+		if tc.context == "slow" && r.Latency < timeout {
+			t.Errorf("slow: expected the probe to wait for the timeout, took %v", r.Latency)
+		}
+	}
+}
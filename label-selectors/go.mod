@@ -3,5 +3,8 @@ module github.com/iximiuz/client-go-examples/label-selectors
 go 1.22.10
 
 require (
+	github.com/iximiuz/client-go-examples/golden v0.0.0
 	k8s.io/apimachinery v0.30.1
 )
+
+replace github.com/iximiuz/client-go-examples/golden => ../golden
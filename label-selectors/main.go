@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
@@ -16,9 +18,7 @@ func main() {
 		panic(err.Error())
 	}
 	sel = sel.Add(*req)
-	if sel.Matches(lbls) {
-		fmt.Printf("Selector %v matched label set %v\n", sel, lbls)
-	} else {
+	if !match(os.Stdout, sel, lbls) {
 		panic("Selector should have matched labels")
 	}
 
@@ -27,9 +27,16 @@ func main() {
 	if err != nil {
 		panic(err.Error())
 	}
-	if sel.Matches(lbls) {
-		fmt.Printf("Selector %v matched label set %v\n", sel, lbls)
-	} else {
+	if !match(os.Stdout, sel, lbls) {
 		panic("Selector should have matched labels")
 	}
 }
+
+func match(w io.Writer, sel labels.Selector, lbls labels.Set) bool {
+	if sel.Matches(lbls) {
+		fmt.Fprintf(w, "Selector %v matched label set %v\n", sel, lbls)
+		return true
+	}
+	fmt.Fprintf(w, "Selector %v didn't match label set %v\n", sel, lbls)
+	return false
+}
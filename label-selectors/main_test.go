@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func TestMatch(t *testing.T) {
+	parsed := func(expr string) func() (labels.Selector, error) {
+		return func() (labels.Selector, error) {
+			return labels.Parse(expr)
+		}
+	}
+	required := func(key string, op selection.Operator, vals ...string) func() (labels.Selector, error) {
+		return func() (labels.Selector, error) {
+			req, err := labels.NewRequirement(key, op, vals)
+			if err != nil {
+				return nil, err
+			}
+			return labels.NewSelector().Add(*req), nil
+		}
+	}
+
+	lbls := labels.Set{"foo": "bar", "baz": "qux", "replicas": "3"}
+
+	var out bytes.Buffer
+	for _, tc := range []struct {
+		name     string
+		selector func() (labels.Selector, error)
+		labels   labels.Set
+	}{
+		{"requirement", required("foo", selection.Equals, "bar"), lbls},
+		{"requirement-in", required("foo", selection.In, "bar", "baz"), lbls},
+		{"requirement-invalid-value", required("foo", selection.Equals, "bar baz"), lbls},
+		{"requirement-no-values", required("foo", selection.In), lbls},
+		{"equals", parsed("foo=bar"), lbls},
+		{"double-equals", parsed("foo==bar"), lbls},
+		{"not-equals", parsed("foo!=bar"), lbls},
+		{"not-equals-missing-key", parsed("missing!=bar"), lbls},
+		{"and", parsed("foo=bar,baz=qux"), lbls},
+		{"in", parsed("foo in (bar, baz)"), lbls},
+		{"notin", parsed("foo notin (bar)"), lbls},
+		{"exists", parsed("foo"), lbls},
+		{"does-not-exist", parsed("!foo"), lbls},
+		{"greater-than", parsed("replicas>2"), lbls},
+		{"less-than-not-a-number", parsed("foo<2"), lbls},
+		{"empty-selector", parsed(""), lbls},
+		{"empty-set", parsed("!foo"), labels.Set{}},
+		{"nil-set", parsed("foo=bar"), nil},
+		{"prefixed-key", parsed("example.com/foo=bar"), labels.Set{"example.com/foo": "bar"}},
+		{"empty-value", parsed("foo="), labels.Set{"foo": ""}},
+		{"unicode-key", parsed("ключ=bar"), labels.Set{"ключ": "bar"}},
+		{"unicode-value", parsed("foo=значение"), labels.Set{"foo": "значение"}},
+		{"invalid-syntax", parsed("foo=bar,"), lbls},
+	} {
+		fmt.Fprintf(&out, "%s: ", tc.name)
+
+		sel, err := tc.selector()
+		if err != nil {
+			fmt.Fprintf(&out, "error: %v\n", err)
+			continue
+		}
+		match(&out, sel, tc.labels)
+	}
+
+	golden.Assert(t, "match", out.Bytes())
+}
@@ -0,0 +1,14 @@
+module github.com/iximiuz/client-go-examples/lifecycle
+
+go 1.22.10
+
+require (
+	github.com/go-logr/logr v1.4.1
+	github.com/iximiuz/client-go-examples/fakeclient v0.0.0
+	k8s.io/api v0.30.1
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+	k8s.io/klog/v2 v2.120.1
+)
+
+replace github.com/iximiuz/client-go-examples/fakeclient => ../fakeclient
@@ -0,0 +1,348 @@
+// Package lifecycle makes sure the example programs leave nothing behind
+// in the cluster - whether they finish normally, panic, or get
+// interrupted with Ctrl+C:
+//
+//	lc := lifecycle.New("list-typed-simple", lifecycle.ConfigMaps)
+//	lc.AddFlags(flag.CommandLine)
+//
+//	config := bootstrap.ConfigOrDie()
+//
+//	ctx := lc.Start(context.Background(), config)
+//	defer lc.Finish()
+//
+//	cm, err := client.CoreV1().ConfigMaps(ns).Create(ctx, desired, metav1.CreateOptions{})
+//	...
+//	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+//		return client.CoreV1().ConfigMaps(ns).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+//	})
+//
+// The context returned by Start() is cancelled on SIGINT or SIGTERM, so
+// the API calls in flight fail, the program panics, and the deferred
+// Finish() deletes the tracked objects. A second signal kills the program
+// right away.
+//
+// Objects left behind anyway (e.g., by a panic in an informer's goroutine,
+// or kill -9) can be swept with --cleanup-only, which deletes everything
+// whose `example` label is exactly the program's name. Programs telling
+// their concurrent runs apart put the run's random suffix into a label of
+// its own (RunLabel) - never into the `example` one.
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// The label the examples mark their objects with.
+const Label = "example"
+
+// RunLabel tells apart the objects of the concurrent runs of a program.
+const RunLabel = "example-run"
+
+var (
+	ConfigMaps           = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	Secrets              = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	Pods                 = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	Deployments          = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	PodDisruptionBudgets = schema.GroupVersionResource{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"}
+)
+
+// Replaced in tests.
+var exit = os.Exit
+
+type Lifecycle struct {
+	program    string
+	resources  []schema.GroupVersionResource
+	finalizers []string
+
+	cleanupOnly    bool
+	cleanupTimeout time.Duration
+
+	logger logr.Logger
+
+	mu          sync.Mutex
+	tracked     []tracked
+	interrupted os.Signal
+	stop        func()
+}
+
+type tracked struct {
+	kind      string
+	namespace string
+	name      string
+	delete    func(ctx context.Context) error
+}
+
+// New returns a Lifecycle for the program creating objects of the given
+// resources (the ones --cleanup-only looks at).
+func New(program string, resources ...schema.GroupVersionResource) *Lifecycle {
+	return &Lifecycle{
+		program:        program,
+		resources:      resources,
+		cleanupTimeout: 10 * time.Second,
+	}
+}
+
+// StripFinalizers makes --cleanup-only remove the given finalizers from
+// the leftovers before deleting them. Programs adding finalizers of their
+// own must list them - nobody else would remove them, and the leftovers
+// would be stuck in Terminating forever.
+func (l *Lifecycle) StripFinalizers(finalizers ...string) {
+	l.finalizers = append(l.finalizers, finalizers...)
+}
+
+func (l *Lifecycle) AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&l.cleanupOnly, "cleanup-only", false, "delete the objects left behind by the previous runs and exit")
+	fs.DurationVar(&l.cleanupTimeout, "cleanup-timeout", l.cleanupTimeout, "how long to wait for the cleanup to finish")
+}
+
+// Start returns the context for the program's API calls derived from
+// parent. With --cleanup-only, it sweeps the leftovers and exits instead.
+// The cleanup logs with the logger from parent.
+func (l *Lifecycle) Start(parent context.Context, config *rest.Config) context.Context {
+	l.logger = klog.FromContext(parent)
+
+	if l.cleanupOnly {
+		client, err := dynamic.NewForConfig(config)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		ctx, cancel := context.WithTimeout(parent, l.cleanupTimeout)
+		defer cancel()
+
+		deleted, err := l.Sweep(ctx, client)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		l.logger.Info("Swept the leftovers", "deleted", deleted)
+		exit(0)
+	}
+
+	ctx, cancel := context.WithCancel(NewContext(parent, l))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			l.mu.Lock()
+			l.interrupted = sig
+			l.mu.Unlock()
+
+			// The next signal gets the default treatment.
+			signal.Stop(signals)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	l.stop = func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+
+	return ctx
+}
+
+// Finish deletes the tracked objects. It must be deferred right after
+// Start(), so that it runs (and recovers) when the program panics.
+// Panics are re-raised after the cleanup. Interrupted programs exit with
+// status 1.
+func (l *Lifecycle) Finish() {
+	r := recover()
+
+	l.mu.Lock()
+	interrupted := l.interrupted
+	l.mu.Unlock()
+
+	switch {
+	case interrupted != nil:
+		l.log().Info("Interrupted, cleaning up", "signal", interrupted.String())
+	case r != nil:
+		l.log().Info("Panicked, cleaning up", "panic", r)
+	}
+
+	l.Cleanup()
+
+	if l.stop != nil {
+		l.stop()
+	}
+
+	if interrupted != nil {
+		exit(1)
+		return
+	}
+	if r != nil {
+		panic(r)
+	}
+}
+
+// Cleanup deletes the tracked objects, the most recently created first.
+// Objects deleted already are skipped silently.
+func (l *Lifecycle) Cleanup() {
+	l.mu.Lock()
+	objects := l.tracked
+	l.tracked = nil
+	l.mu.Unlock()
+
+	// Not derived from the program's context - it's likely cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), l.cleanupTimeout)
+	defer cancel()
+
+	logger := l.log()
+	for i := len(objects) - 1; i >= 0; i-- {
+		obj := objects[i]
+		ref := klog.KRef(obj.namespace, obj.name)
+
+		err := obj.delete(ctx)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "Failed to clean up", "kind", obj.kind, "object", ref)
+			continue
+		}
+		logger.Info("Cleaned up", "kind", obj.kind, "object", ref)
+	}
+}
+
+// Sweep deletes the objects of the program's resources labeled by its
+// previous runs in all namespaces and returns how many were deleted. The
+// finalizers passed to StripFinalizers() are removed first.
+func (l *Lifecycle) Sweep(ctx context.Context, client dynamic.Interface) (int, error) {
+	logger := klog.FromContext(ctx)
+
+	deleted := 0
+	for _, resource := range l.resources {
+		list, err := client.
+			Resource(resource).
+			Namespace(metav1.NamespaceAll).
+			List(ctx, metav1.ListOptions{LabelSelector: Label + "=" + l.program})
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, obj := range list.Items {
+			objects := client.Resource(resource).Namespace(obj.GetNamespace())
+
+			stripped, err := l.stripFinalizers(ctx, objects, &obj)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return deleted, err
+			}
+
+			err = objects.Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+			switch {
+			case apierrors.IsNotFound(err) && stripped && obj.GetDeletionTimestamp() != nil:
+				// It was Terminating, and is gone with its last finalizer.
+			case apierrors.IsNotFound(err):
+				continue
+			case err != nil:
+				return deleted, err
+			}
+
+			logger.Info("Deleted leftover", "kind", obj.GetKind(), "object", klog.KObj(&obj), "gvr", resource.String())
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// stripFinalizers removes the finalizers passed to StripFinalizers() from
+// the object, keeping the others. The patch carries the resourceVersion, so
+// it fails with a Conflict rather than drops the finalizers added since the
+// object was listed. Reports whether there was anything to remove.
+func (l *Lifecycle) stripFinalizers(ctx context.Context, client dynamic.ResourceInterface, obj *unstructured.Unstructured) (bool, error) {
+	var kept []string
+	for _, f := range obj.GetFinalizers() {
+		if !slices.Contains(l.finalizers, f) {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(obj.GetFinalizers()) {
+		return false, nil
+	}
+
+	// A nil list becomes "finalizers":null, which removes the field.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      kept,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := client.Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return false, err
+	}
+
+	klog.FromContext(ctx).Info("Removed finalizers from leftover", "kind", obj.GetKind(), "object", klog.KObj(obj), "finalizers", l.finalizers)
+	return true, nil
+}
+
+// log returns the logger Start() was given, or klog's global one if the
+// Lifecycle wasn't started (e.g., in tests).
+func (l *Lifecycle) log() logr.Logger {
+	if l.logger.GetSink() == nil {
+		return klog.Background()
+	}
+	return l.logger
+}
+
+func (l *Lifecycle) track(obj tracked) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tracked = append(l.tracked, obj)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the Lifecycle, so that Track()
+// calls deep in the program can find it. Start() does it on its own -
+// call it directly only in tests.
+func NewContext(ctx context.Context, l *Lifecycle) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// Track registers the object for deletion at the end of the program.
+// It's a no-op if ctx carries no Lifecycle.
+func Track(ctx context.Context, kind string, obj metav1.Object, delete func(ctx context.Context) error) {
+	l, ok := ctx.Value(contextKey{}).(*Lifecycle)
+	if !ok {
+		return
+	}
+
+	l.track(tracked{
+		kind:      kind,
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+		delete:    delete,
+	})
+}
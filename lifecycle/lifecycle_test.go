@@ -0,0 +1,246 @@
+package lifecycle
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+)
+
+func newConfigMap(name string, labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    labels,
+		},
+	}
+}
+
+func createTracked(t *testing.T, ctx context.Context, client kubernetes.Interface, name string) {
+	t.Helper()
+
+	cm, err := client.CoreV1().ConfigMaps("default").Create(ctx, newConfigMap(name, nil), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps("default").Delete(ctx, cm.Name, metav1.DeleteOptions{})
+	})
+}
+
+func configMapNames(t *testing.T, client kubernetes.Interface) []string {
+	t.Helper()
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, cm := range list.Items {
+		names = append(names, cm.Name)
+	}
+	return names
+}
+
+func TestCleanup(t *testing.T) {
+	client := fakeclient.NewClientset(newConfigMap("unrelated", nil))
+
+	lc := New("test")
+	ctx := NewContext(context.Background(), lc)
+
+	createTracked(t, ctx, client, "foo")
+	createTracked(t, ctx, client, "bar")
+
+	// Deleted by the program itself - must be skipped.
+	if err := client.CoreV1().ConfigMaps("default").Delete(ctx, "bar", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	lc.Finish()
+
+	if names := configMapNames(t, client); len(names) != 1 || names[0] != "unrelated" {
+		t.Errorf("expected only the unrelated ConfigMap to survive, got %v", names)
+	}
+
+	var deletes []string
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "delete" {
+			deletes = append(deletes, action.(interface{ GetName() string }).GetName())
+		}
+	}
+	// The program's delete, then the cleanup in reverse order.
+	if len(deletes) != 3 || deletes[1] != "bar" || deletes[2] != "foo" {
+		t.Errorf("unexpected deletes %v", deletes)
+	}
+}
+
+func TestTrackWithoutLifecycle(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	// Must not blow up.
+	createTracked(t, context.Background(), client, "foo")
+
+	if names := configMapNames(t, client); len(names) != 1 {
+		t.Errorf("expected the ConfigMap to stay, got %v", names)
+	}
+}
+
+func TestFinishOnPanic(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	lc := New("test")
+	ctx := NewContext(context.Background(), lc)
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected the panic to be re-raised, got %v", r)
+		}
+		if names := configMapNames(t, client); len(names) != 0 {
+			t.Errorf("expected no ConfigMaps left, got %v", names)
+		}
+	}()
+
+	func() {
+		defer lc.Finish()
+
+		createTracked(t, ctx, client, "foo")
+		panic("boom")
+	}()
+}
+
+func TestFinishOnSignal(t *testing.T) {
+	defer func(orig func(int)) { exit = orig }(exit)
+
+	code := -1
+	exit = func(c int) { code = c }
+
+	client := fakeclient.NewClientset()
+
+	lc := New("test")
+	ctx := NewContext(context.Background(), lc)
+
+	createTracked(t, ctx, client, "foo")
+
+	// What Start() does on SIGTERM.
+	lc.interrupted = syscall.SIGTERM
+
+	func() {
+		defer lc.Finish()
+
+		// The API calls fail once the context is cancelled.
+		panic("context canceled")
+	}()
+
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if names := configMapNames(t, client); len(names) != 0 {
+		t.Errorf("expected no ConfigMaps left, got %v", names)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	configMap := func(name string, labels map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		obj.SetLabels(labels)
+		return obj
+	}
+
+	client := fakeclient.NewDynamicClient(
+		configMap("plain", map[string]string{Label: "test"}),
+		configMap("run", map[string]string{Label: "test", RunLabel: "abc123"}),
+		// Another program whose name starts with this one's.
+		configMap("other", map[string]string{Label: "test-finalizer"}),
+		configMap("unlabeled", nil),
+	)
+
+	deleted, err := New("test", ConfigMaps).Sweep(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 objects deleted, got %d", deleted)
+	}
+
+	list, err := client.Resource(ConfigMaps).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var left []string
+	for _, obj := range list.Items {
+		left = append(left, obj.GetName())
+	}
+	if len(left) != 2 || left[0] != "other" || left[1] != "unlabeled" {
+		t.Errorf("expected other and unlabeled to survive, got %v", left)
+	}
+}
+
+func TestSweepStripsFinalizers(t *testing.T) {
+	const (
+		ours   = "example.com/ours"
+		theirs = "example.com/theirs"
+	)
+
+	configMap := func(name string, finalizers []string, terminating bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		obj.SetResourceVersion("1")
+		obj.SetLabels(map[string]string{Label: "test"})
+		obj.SetFinalizers(finalizers)
+		if terminating {
+			now := metav1.Now()
+			obj.SetDeletionTimestamp(&now)
+		}
+		return obj
+	}
+
+	client := fakeclient.NewDynamicClient(
+		configMap("finalized", []string{ours}, false),
+		// Left by a program killed in the middle of the deletion.
+		configMap("terminating", []string{ours}, true),
+		// Someone else's finalizer isn't ours to remove.
+		configMap("shared", []string{ours, theirs}, false),
+	)
+
+	lc := New("test", ConfigMaps)
+	lc.StripFinalizers(ours)
+
+	deleted, err := lc.Sweep(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 objects deleted, got %d", deleted)
+	}
+
+	list, err := client.Resource(ConfigMaps).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].GetName() != "shared" {
+		t.Fatalf("expected only the shared ConfigMap to stay, got %v", list.Items)
+	}
+
+	shared := list.Items[0]
+	if shared.GetDeletionTimestamp() == nil {
+		t.Error("expected the shared ConfigMap to be Terminating")
+	}
+	if finalizers := shared.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != theirs {
+		t.Errorf("expected only the other finalizer to stay, got %v", finalizers)
+	}
+}
@@ -2,40 +2,50 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path"
+	"flag"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("list-typed-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "list-typed-simple"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	namespace := "default"
-	label := "list-typed-simple-" + rand.String(6)
+	run(ctx, client, "default")
+}
+
+// run returns the number of ConfigMaps found by the label selector.
+func run(ctx context.Context, client kubernetes.Interface, namespace string) int {
+	logger := klog.FromContext(ctx)
+
+	// Tells this run's objects from the ones of the concurrent runs.
+	runID := rand.String(6)
+	selector := "example==list-typed-simple,example-run==" + runID
 
 	desired := corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
 	desired.Namespace = namespace
 	desired.GenerateName = "list-typed-simple-"
-	desired.SetLabels(map[string]string{"example": label})
+	desired.SetLabels(map[string]string{"example": "list-typed-simple", "example-run": runID})
 
 	// Create a bunch of objects first.
 	for i := 0; i < 10; i++ {
@@ -43,29 +53,35 @@ func main() {
 			CoreV1().
 			ConfigMaps(namespace).
 			Create(
-				context.Background(),
+				ctx,
 				&desired,
 				metav1.CreateOptions{},
 			)
 		if err != nil {
 			panic(err.Error())
 		}
-		fmt.Printf("Created ConfigMap %s/%s\n", namespace, created.GetName())
+		logger.Info("Created ConfigMap", "configMap", klog.KObj(created), "resourceVersion", created.GetResourceVersion())
+
+		// Deleted when the program exits - even if it's interrupted.
+		lifecycle.Track(ctx, "ConfigMap", created, func(ctx context.Context) error {
+			return client.CoreV1().ConfigMaps(namespace).Delete(ctx, created.GetName(), metav1.DeleteOptions{})
+		})
 	}
 
-	// List - filter by the `example` label.
+	// List - filter by the `example` and `example-run` labels.
 	list, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		List(
-			context.Background(),
+			ctx,
 			metav1.ListOptions{
-				LabelSelector: "example==" + label,
+				LabelSelector: selector,
 			},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Found %d ConfigMap objects\n", len(list.Items))
+	logger.Info("Listed ConfigMaps", "labelSelector", selector, "found", len(list.Items), "resourceVersion", list.ResourceVersion)
+	return len(list.Items)
 }
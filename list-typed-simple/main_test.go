@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRun(t *testing.T) {
+	// An object of a concurrent run the label selector must filter out.
+	client := fakeclient.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unrelated",
+			Namespace: "default",
+			Labels:    map[string]string{"example": "list-typed-simple", "example-run": "concurrent"},
+		},
+	})
+
+	lc := lifecycle.New("list-typed-simple")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	if found := run(ctx, client, "default"); found != 10 {
+		t.Errorf("expected 10 ConfigMaps, found %d", found)
+	}
+
+	lc.Finish()
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "unrelated" {
+		t.Errorf("expected only the unrelated ConfigMap to survive the cleanup, got %d ConfigMaps", len(list.Items))
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/iximiuz/client-go-examples/logging
+
+go 1.22.10
+
+require (
+	github.com/go-logr/logr v1.4.1
+	k8s.io/klog/v2 v2.120.1
+)
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestJSON(t *testing.T) {
+	defer klog.ClearLogger()
+
+	var out bytes.Buffer
+	o := Options{out: &out}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o.AddFlags(fs)
+	if err := fs.Parse([]string{"-v=2", "--log-format=json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := o.NewContext(context.Background(), "test")
+
+	logger := klog.FromContext(ctx).WithValues("worker", 1)
+	logger.Info("Created ConfigMap", "configMap", klog.KRef("default", "foo"), "resourceVersion", "42")
+	logger.V(2).Info("Visible at -v=2")
+	logger.V(3).Info("Hidden at -v=2")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d:\n%s", len(lines), out.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("not a JSON log line %q: %v", lines[0], err)
+	}
+
+	for key, want := range map[string]interface{}{
+		"logger":          "test",
+		"msg":             "Created ConfigMap",
+		"worker":          float64(1),
+		"resourceVersion": "42",
+		"configMap":       map[string]interface{}{"name": "foo", "namespace": "default"},
+	} {
+		if got := entry[key]; !equalJSON(got, want) {
+			t.Errorf("unexpected %q: got %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+
+	o := Options{Format: "xml"}
+	o.NewContext(context.Background(), "test")
+}
+
+func equalJSON(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
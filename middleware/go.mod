@@ -0,0 +1,10 @@
+module github.com/iximiuz/client-go-examples/middleware
+
+go 1.22.10
+
+require (
+	github.com/go-logr/logr v1.4.1
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+	k8s.io/klog/v2 v2.120.1
+)
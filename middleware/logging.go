@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Logging logs every request and response with the logger from the
+// request's context (the one the API call was made with), named "http".
+// The credentials in the headers are masked.
+func Logging(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		logger := klog.FromContext(req.Context()).WithName("http")
+		if id := req.Header.Get(RequestIDHeader); id != "" {
+			logger = logger.WithValues("requestID", id)
+		}
+
+		logger.Info("Request", "method", req.Method, "url", req.URL.String(), "headers", redact(req.Header))
+
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		latency := time.Since(start)
+		if err != nil {
+			logger.Error(err, "Request failed", "latency", latency)
+			return nil, err
+		}
+
+		// The Audit-Id is how to find the request in the API server's
+		// audit log.
+		logger.Info("Response", "status", resp.StatusCode, "latency", latency,
+			"auditID", resp.Header.Get("Audit-Id"), "headers", redact(resp.Header))
+		return resp, nil
+	})
+}
+
+// Headers whose values are credentials.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// redact returns a copy of the headers with the credentials masked, but
+// the authentication scheme ("Bearer", "Basic") kept. Unlike client-go's
+// "<masked>" at -v=8, the brackets don't get escaped in JSON.
+func redact(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, name := range sensitiveHeaders {
+		values := redacted[name]
+		for i, value := range values {
+			scheme, _, found := strings.Cut(value, " ")
+			if !found {
+				values[i] = "[masked]"
+				continue
+			}
+			values[i] = scheme + " [masked]"
+		}
+	}
+	return redacted
+}
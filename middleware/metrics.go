@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Key identifies what a request does - the way the API server's metrics
+// and audit log do it.
+type Key struct {
+	Verb        string
+	Resource    schema.GroupVersionResource
+	Subresource string
+
+	// Path of the non-resource requests (discovery, /version, etc.),
+	// which have no Resource.
+	Path string
+}
+
+func (k Key) String() string {
+	if k.Path != "" {
+		return k.Verb + " " + k.Path
+	}
+
+	s := k.Verb + " " + k.Resource.Resource
+	if k.Subresource != "" {
+		s += "/" + k.Subresource
+	}
+	return s + "." + k.Resource.GroupVersion().String()
+}
+
+// Stats of the requests with the same Key.
+type Stats struct {
+	Requests int
+	// Statuses counts the responses by their HTTP status code. The
+	// requests that got no response at all are counted as 0.
+	Statuses map[int]int
+	// Latency until the response headers arrive - for watches, that's
+	// when the watch is established.
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// Metrics counts the requests going through the transports it wraps, by
+// verb and resource.
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[Key]*Stats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{stats: map[Key]*Stats{}}
+}
+
+// Wrap has the signature of rest.Config.WrapTransport.
+func (m *Metrics) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		latency := time.Since(start)
+
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		m.observe(keyOf(req), status, latency)
+
+		return resp, err
+	})
+}
+
+func (m *Metrics) observe(key Key, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.stats[key]
+	if !ok {
+		s = &Stats{Statuses: map[int]int{}}
+		m.stats[key] = s
+	}
+	s.Requests++
+	s.Statuses[status]++
+	s.TotalLatency += latency
+	s.MaxLatency = max(s.MaxLatency, latency)
+}
+
+// Snapshot returns a copy of the stats collected so far.
+func (m *Metrics) Snapshot() map[Key]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := map[Key]Stats{}
+	for key, s := range m.stats {
+		c := *s
+		c.Statuses = map[int]int{}
+		for status, n := range s.Statuses {
+			c.Statuses[status] = n
+		}
+		snapshot[key] = c
+	}
+	return snapshot
+}
+
+// Print writes the stats as a table, one row per Key.
+func (m *Metrics) Print(out io.Writer) {
+	snapshot := m.Snapshot()
+
+	keys := make([]Key, 0, len(snapshot))
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST\tCOUNT\tSTATUSES\tAVG LATENCY\tMAX LATENCY")
+	for _, key := range keys {
+		s := snapshot[key]
+
+		statuses := make([]int, 0, len(s.Statuses))
+		for status := range s.Statuses {
+			statuses = append(statuses, status)
+		}
+		slices.Sort(statuses)
+
+		var counts []string
+		for _, status := range statuses {
+			counts = append(counts, fmt.Sprintf("%d=%d", status, s.Statuses[status]))
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%v\t%v\n", key, s.Requests, strings.Join(counts, " "),
+			(s.TotalLatency / time.Duration(s.Requests)).Round(time.Microsecond), s.MaxLatency.Round(time.Microsecond))
+	}
+	w.Flush()
+}
+
+// keyOf tells the verb and the resource of the request from its method and
+// URL, like the API server's RequestInfoFactory (minus the deprecated
+// /watch/ paths):
+//
+//	/api/v1/namespaces/{namespace}/{resource}/{name}/{subresource}
+//	/apis/{group}/{version}/{resource}/{name}/{subresource}
+func keyOf(req *http.Request) Key {
+	verb := strings.ToLower(req.Method)
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	var gv schema.GroupVersion
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		gv, parts = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		gv, parts = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return Key{Verb: verb, Path: req.URL.Path}
+	}
+
+	// The namespaces have subresources, too - /namespaces/{name}/status.
+	if parts[0] == "namespaces" && len(parts) > 2 && parts[2] != "status" && parts[2] != "finalize" {
+		parts = parts[2:]
+	}
+
+	key := Key{Resource: gv.WithResource(parts[0])}
+	var name string
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		key.Subresource = parts[2]
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case name != "":
+			key.Verb = "get"
+		case req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1":
+			key.Verb = "watch"
+		default:
+			key.Verb = "list"
+		}
+	case http.MethodPost:
+		key.Verb = "create"
+	case http.MethodPut:
+		key.Verb = "update"
+	case http.MethodPatch:
+		key.Verb = "patch"
+	case http.MethodDelete:
+		key.Verb = "delete"
+		if name == "" {
+			key.Verb = "deletecollection"
+		}
+	default:
+		key.Verb = verb
+	}
+	return key
+}
@@ -0,0 +1,94 @@
+// Package middleware provides HTTP round trippers to plug into
+// rest.Config.WrapTransport, so the example programs can see (and shape)
+// the requests client-go sends without -v=8:
+//
+//	metrics := middleware.NewMetrics()
+//	middleware.Wrap(config,
+//		middleware.RequestID,
+//		middleware.Headers(http.Header{"X-Client-Go-Example": {"crud-typed-simple"}}),
+//		metrics.Wrap,
+//		middleware.Logging,
+//	)
+//	...
+//	metrics.Print(os.Stdout)
+//
+// The wrapped transport is the innermost layer of client-go's stack: the
+// requests have passed the rate limiter, and the authentication and
+// User-Agent headers are set already. The responses haven't been decoded
+// yet, and the retries show up as separate requests.
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// Wrap plugs the wrappers into the config, the first one being the
+// outermost - the first to see the request and the last to see the
+// response. Put Logging last to log the headers the others add.
+func Wrap(config *rest.Config, wrappers ...transport.WrapperFunc) {
+	// config.Wrap() puts every next wrapper on top of the previous ones.
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		config.Wrap(wrappers[i])
+	}
+}
+
+// Headers sets the headers on every request, replacing the values set
+// by client-go (if any).
+func Headers(headers http.Header) transport.WrapperFunc {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// Round trippers must not modify the caller's request.
+			req = req.Clone(req.Context())
+			for name, values := range headers {
+				req.Header[http.CanonicalHeaderKey(name)] = values
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// RequestIDHeader is the header carrying the request ID. The Kubernetes
+// API server doesn't look at it (it responds with its own Audit-Id), but
+// the proxies and load balancers in front of it often log it.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID, so that
+// all the API calls made with it share the ID - e.g., the retries of an
+// update on conflict.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID the ctx carries, if any.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID sets the X-Request-Id header of every request to the ID from
+// the request's context, or to a random one.
+func RequestID(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id := RequestIDFrom(req.Context())
+		if id == "" {
+			id = string(uuid.NewUUID())
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+		return rt.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// newServer returns a server responding to anything with an empty
+// ConfigMap list (or 404 for the deletes), and a func returning the
+// headers of the last request.
+func newServer(t *testing.T) (*httptest.Server, func() http.Header) {
+	var (
+		mu   sync.Mutex
+		last http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		last = r.Header.Clone()
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Audit-Id", "audit-1")
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"ConfigMapList","apiVersion":"v1","items":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestWrap(t *testing.T) {
+	server, lastHeaders := newServer(t)
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	logger := funcr.New(func(prefix, args string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, prefix+" "+args)
+	}, funcr.Options{})
+	ctx := klog.NewContext(context.Background(), logger)
+
+	metrics := NewMetrics()
+	config := &rest.Config{Host: server.URL, BearerToken: "s3cr3t"}
+	Wrap(config,
+		RequestID,
+		Headers(http.Header{"x-client-go-example": {"test"}}),
+		metrics.Wrap,
+		Logging,
+	)
+	client := kubernetes.NewForConfigOrDie(config)
+
+	if _, err := client.CoreV1().ConfigMaps("default").List(WithRequestID(ctx, "req-1"), metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := lastHeaders()
+	if got := headers.Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("expected the request ID from the context, got %q", got)
+	}
+	if got := headers.Get("X-Client-Go-Example"); got != "test" {
+		t.Errorf("expected the injected header, got %q", got)
+	}
+
+	// A random ID for the requests without one.
+	if err := client.CoreV1().ConfigMaps("default").Delete(ctx, "foo", metav1.DeleteOptions{}); err == nil {
+		t.Fatal("expected the delete to fail")
+	}
+	if got := lastHeaders().Get(RequestIDHeader); got == "" || got == "req-1" {
+		t.Errorf("expected a random request ID, got %q", got)
+	}
+
+	mu.Lock()
+	log := strings.Join(lines, "\n")
+	mu.Unlock()
+
+	if strings.Contains(log, "s3cr3t") {
+		t.Errorf("expected the token to be masked, got:\n%s", log)
+	}
+	for _, want := range []string{
+		`"msg"="Request" "requestID"="req-1" "method"="GET"`,
+		`"Authorization"=["Bearer [masked]"]`,
+		`"X-Client-Go-Example"=["test"]`,
+		`"msg"="Response" "requestID"="req-1" "status"=200`,
+		`"auditID"="audit-1"`,
+		`"status"=404`,
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("expected %s in the log, got:\n%s", want, log)
+		}
+	}
+
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	snapshot := metrics.Snapshot()
+	if s := snapshot[Key{Verb: "list", Resource: configMaps}]; s.Requests != 1 || s.Statuses[200] != 1 {
+		t.Errorf("expected a successful list, got %+v", s)
+	}
+	if s := snapshot[Key{Verb: "delete", Resource: configMaps}]; s.Requests != 1 || s.Statuses[404] != 1 {
+		t.Errorf("expected a failed delete, got %+v", s)
+	}
+
+	var out bytes.Buffer
+	metrics.Print(&out)
+	for _, want := range []string{"REQUEST", "list configmaps.v1", "delete configmaps.v1", "404=1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the table, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	namespaces := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	for _, tc := range []struct {
+		method, url string
+		want        Key
+	}{
+		{"GET", "/api/v1/namespaces/default/pods", Key{Verb: "list", Resource: pods}},
+		{"GET", "/api/v1/pods?watch=true", Key{Verb: "watch", Resource: pods}},
+		{"GET", "/api/v1/namespaces/default/pods/foo", Key{Verb: "get", Resource: pods}},
+		{"POST", "/api/v1/namespaces/default/pods/foo/eviction", Key{Verb: "create", Resource: pods, Subresource: "eviction"}},
+		{"DELETE", "/api/v1/namespaces/default/pods", Key{Verb: "deletecollection", Resource: pods}},
+		{"GET", "/api/v1/namespaces", Key{Verb: "list", Resource: namespaces}},
+		{"DELETE", "/api/v1/namespaces/default", Key{Verb: "delete", Resource: namespaces}},
+		{"PUT", "/api/v1/namespaces/default/finalize", Key{Verb: "update", Resource: namespaces, Subresource: "finalize"}},
+		{"PATCH", "/apis/apps/v1/namespaces/default/deployments/foo", Key{Verb: "patch", Resource: deployments}},
+		{"PUT", "/apis/apps/v1/namespaces/default/deployments/foo/scale", Key{Verb: "update", Resource: deployments, Subresource: "scale"}},
+		{"GET", "/apis/apps/v1", Key{Verb: "get", Path: "/apis/apps/v1"}},
+		{"GET", "/version", Key{Verb: "get", Path: "/version"}},
+	} {
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		if got := keyOf(req); got != tc.want {
+			t.Errorf("%s %s: got %+v, want %+v", tc.method, tc.url, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const prog = "owner-references"
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New(prog, lifecycle.ConfigMaps, lifecycle.Secrets)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), prog), config)
+	defer lc.Finish()
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	run(ctx, client, "default")
+}
+
+// run deletes the same family of objects with every propagation policy.
+func run(ctx context.Context, client kubernetes.Interface, namespace string) {
+	background(ctx, client, namespace)
+	foreground(ctx, client, namespace)
+	orphan(ctx, client, namespace)
+}
+
+// family is an owner ConfigMap with its dependents:
+//   - blocking, a ConfigMap blocking the owner's foreground deletion
+//     (blockOwnerDeletion) - the owner's controller, too;
+//   - loose, a Secret that doesn't block it;
+//   - shared, a ConfigMap with one more owner, other.
+type family struct {
+	owner, other     *corev1.ConfigMap
+	blocking, shared *corev1.ConfigMap
+	loose            *corev1.Secret
+}
+
+// Background: the owner is deleted right away, the garbage collector
+// deletes the dependents afterwards. The shared dependent stays - it has
+// another owner - and only loses the reference to the deleted one.
+func background(ctx context.Context, client kubernetes.Interface, namespace string) {
+	f := createFamily(ctx, client, namespace, metav1.DeletePropagationBackground)
+
+	events := deleteOwner(ctx, client, f, metav1.DeletePropagationBackground, func(events []event) bool {
+		return index(events, watch.Deleted, f.owner) >= 0 &&
+			index(events, watch.Deleted, f.blocking) >= 0 &&
+			index(events, watch.Deleted, f.loose) >= 0 &&
+			ownedOnlyBy(last(events, f.shared), f.other)
+	})
+
+	if index(events, watch.Deleted, f.owner) > index(events, watch.Deleted, f.blocking) {
+		panic("Dependent deleted before its owner in the background")
+	}
+
+	deleteOther(ctx, client, f)
+}
+
+// Foreground: the owner stays, marked with deletionTimestamp and the
+// foregroundDeletion finalizer, until the garbage collector deletes the
+// dependents blocking its deletion. The rest of them go in no particular
+// order.
+func foreground(ctx context.Context, client kubernetes.Interface, namespace string) {
+	f := createFamily(ctx, client, namespace, metav1.DeletePropagationForeground)
+
+	events := deleteOwner(ctx, client, f, metav1.DeletePropagationForeground, func(events []event) bool {
+		return index(events, watch.Deleted, f.owner) >= 0 &&
+			index(events, watch.Deleted, f.blocking) >= 0 &&
+			index(events, watch.Deleted, f.loose) >= 0 &&
+			ownedOnlyBy(last(events, f.shared), f.other)
+	})
+
+	marked := index(events, watch.Modified, f.owner)
+	if marked < 0 || events[marked].obj.GetDeletionTimestamp() == nil {
+		panic("Owner wasn't marked for deletion in the foreground")
+	}
+	if index(events, watch.Deleted, f.blocking) > index(events, watch.Deleted, f.owner) {
+		panic("Owner deleted before its blocking dependent in the foreground")
+	}
+
+	deleteOther(ctx, client, f)
+}
+
+// Orphan: the owner stays, marked with deletionTimestamp and the orphan
+// finalizer, until the garbage collector removes the references to it
+// from the dependents. Nothing but the owner is deleted.
+func orphan(ctx context.Context, client kubernetes.Interface, namespace string) {
+	f := createFamily(ctx, client, namespace, metav1.DeletePropagationOrphan)
+
+	events := deleteOwner(ctx, client, f, metav1.DeletePropagationOrphan, func(events []event) bool {
+		return index(events, watch.Deleted, f.owner) >= 0 &&
+			ownedOnlyBy(last(events, f.blocking)) &&
+			ownedOnlyBy(last(events, f.loose)) &&
+			ownedOnlyBy(last(events, f.shared), f.other)
+	})
+
+	for _, dependent := range []metav1.Object{f.blocking, f.loose, f.shared} {
+		if index(events, watch.Deleted, dependent) >= 0 {
+			panic(fmt.Sprintf("Orphan %s deleted", dependent.GetName()))
+		}
+	}
+	if index(events, watch.Modified, f.blocking) > index(events, watch.Deleted, f.owner) {
+		panic("Owner deleted before its dependent was orphaned")
+	}
+
+	// The orphans are on their own now.
+	err := client.CoreV1().ConfigMaps(f.blocking.Namespace).Delete(ctx, f.blocking.Name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	err = client.CoreV1().Secrets(f.loose.Namespace).Delete(ctx, f.loose.Name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Deleted orphans", "configMap", klog.KObj(f.blocking), "secret", klog.KObj(f.loose))
+
+	deleteOther(ctx, client, f)
+}
+
+// deleteOwner deletes the owner with the given propagation policy and
+// watches the family until done() says the garbage collector is done.
+// The events of the ConfigMaps come in the order they happened, while
+// the Secret's are from a different stream - only their presence counts.
+func deleteOwner(
+	ctx context.Context,
+	client kubernetes.Interface,
+	f *family,
+	policy metav1.DeletionPropagation,
+	done func([]event) bool,
+) []event {
+	opts := metav1.ListOptions{LabelSelector: familySelector(policy)}
+
+	// Watching from the resourceVersion of a list skips the ADDED events
+	// of the existing objects.
+	configMapList, err := client.CoreV1().ConfigMaps(f.owner.Namespace).List(ctx, opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	secretList, err := client.CoreV1().Secrets(f.owner.Namespace).List(ctx, opts)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	opts.ResourceVersion = configMapList.ResourceVersion
+	configMaps, err := client.CoreV1().ConfigMaps(f.owner.Namespace).Watch(ctx, opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer configMaps.Stop()
+
+	opts.ResourceVersion = secretList.ResourceVersion
+	secrets, err := client.CoreV1().Secrets(f.owner.Namespace).Watch(ctx, opts)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer secrets.Stop()
+
+	err = client.
+		CoreV1().
+		ConfigMaps(f.owner.Namespace).
+		Delete(
+			ctx,
+			f.owner.Name,
+			metav1.DeleteOptions{PropagationPolicy: &policy},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Deleted owner", "configMap", klog.KObj(f.owner), "propagationPolicy", policy)
+
+	// The garbage collector is a controller - it takes its time.
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var events []event
+	for !done(events) {
+		var e watch.Event
+		var ok bool
+		select {
+		case e, ok = <-configMaps.ResultChan():
+		case e, ok = <-secrets.ResultChan():
+		case <-ctx.Done():
+			panic(fmt.Sprintf("Garbage collector isn't done with %s deletion: %v", policy, ctx.Err()))
+		}
+		if !ok {
+			panic("Watch closed unexpectedly")
+		}
+
+		obj, isObj := e.Object.(metav1.Object)
+		if !isObj {
+			panic(fmt.Sprintf("Unexpected watch event %s %T", e.Type, e.Object))
+		}
+		events = append(events, event{typ: e.Type, obj: obj})
+
+		klog.FromContext(ctx).Info(
+			"Observed event",
+			"type", e.Type,
+			"kind", kind(e.Object),
+			"object", klog.KObj(obj),
+			"owners", ownerNames(obj),
+			"deleting", obj.GetDeletionTimestamp() != nil,
+			"finalizers", obj.GetFinalizers(),
+		)
+	}
+	return events
+}
+
+func kind(obj interface{}) string {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return "ConfigMap"
+	case *corev1.Secret:
+		return "Secret"
+	}
+	return fmt.Sprintf("%T", obj)
+}
+
+type event struct {
+	typ watch.EventType
+	obj metav1.Object
+}
+
+// index returns the position of the first event of the type for the
+// object, or -1.
+func index(events []event, typ watch.EventType, obj metav1.Object) int {
+	for i, e := range events {
+		if e.typ == typ && e.obj.GetUID() == obj.GetUID() {
+			return i
+		}
+	}
+	return -1
+}
+
+// last returns the most recent state of the object seen, or nil.
+func last(events []event, obj metav1.Object) metav1.Object {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].obj.GetUID() == obj.GetUID() {
+			return events[i].obj
+		}
+	}
+	return nil
+}
+
+// ownedOnlyBy tells if the object (still around) refers to exactly the
+// given owners.
+func ownedOnlyBy(obj metav1.Object, owners ...metav1.Object) bool {
+	if obj == nil || len(obj.GetOwnerReferences()) != len(owners) {
+		return false
+	}
+	for i, ref := range obj.GetOwnerReferences() {
+		if ref.UID != owners[i].GetUID() {
+			return false
+		}
+	}
+	return true
+}
+
+func ownerNames(obj metav1.Object) []string {
+	var names []string
+	for _, ref := range obj.GetOwnerReferences() {
+		names = append(names, ref.Kind+"/"+ref.Name)
+	}
+	return names
+}
+
+func createFamily(ctx context.Context, client kubernetes.Interface, namespace string, policy metav1.DeletionPropagation) *family {
+	familyLabels := familyLabels(policy)
+
+	f := &family{
+		owner: createConfigMap(ctx, client, namespace, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: prog + "-owner-", Labels: familyLabels},
+		}),
+		other: createConfigMap(ctx, client, namespace, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: prog + "-other-", Labels: familyLabels},
+		}),
+	}
+
+	// The references need the owners' UIDs - the owners have to exist
+	// first. A namespaced owner can only have dependents in its namespace.
+	blockOwnerDeletion, controller := true, true
+	f.blocking = createConfigMap(ctx, client, namespace, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prog + "-blocking-",
+			Labels:       familyLabels,
+			OwnerReferences: []metav1.OwnerReference{
+				ownerReference(f.owner, &blockOwnerDeletion, &controller),
+			},
+		},
+	})
+	f.shared = createConfigMap(ctx, client, namespace, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prog + "-shared-",
+			Labels:       familyLabels,
+			OwnerReferences: []metav1.OwnerReference{
+				ownerReference(f.owner, nil, nil),
+				ownerReference(f.other, nil, nil),
+			},
+		},
+	})
+
+	loose, err := client.
+		CoreV1().
+		Secrets(namespace).
+		Create(
+			ctx,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: prog + "-loose-",
+					Labels:       familyLabels,
+					OwnerReferences: []metav1.OwnerReference{
+						ownerReference(f.owner, nil, nil),
+					},
+				},
+				Data: map[string][]byte{"password": []byte("s3cr3t")},
+			},
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Created Secret", "secret", klog.KObj(loose), "owners", ownerNames(loose))
+
+	lifecycle.Track(ctx, "Secret", loose, func(ctx context.Context) error {
+		return client.CoreV1().Secrets(namespace).Delete(ctx, loose.Name, metav1.DeleteOptions{})
+	})
+	f.loose = loose
+
+	return f
+}
+
+// ownerReference points to the owner ConfigMap. The API server checks
+// nothing but the fields being set - a reference to an object that doesn't
+// exist (or has a different UID) is collected as dangling.
+func ownerReference(owner *corev1.ConfigMap, blockOwnerDeletion, controller *bool) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion:         "v1",
+		Kind:               "ConfigMap",
+		Name:               owner.Name,
+		UID:                owner.UID,
+		BlockOwnerDeletion: blockOwnerDeletion,
+		Controller:         controller,
+	}
+}
+
+func createConfigMap(ctx context.Context, client kubernetes.Interface, namespace string, desired *corev1.ConfigMap) *corev1.ConfigMap {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Create(ctx, desired, metav1.CreateOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "owners", ownerNames(cm))
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+	})
+	return cm
+}
+
+// deleteOther deletes the other owner, and the shared dependent with it.
+func deleteOther(ctx context.Context, client kubernetes.Interface, f *family) {
+	err := client.CoreV1().ConfigMaps(f.other.Namespace).Delete(ctx, f.other.Name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Deleted owner", "configMap", klog.KObj(f.other))
+}
+
+func familyLabels(policy metav1.DeletionPropagation) map[string]string {
+	return map[string]string{
+		lifecycle.Label: prog,
+		"policy":        strings.ToLower(string(policy)),
+	}
+}
+
+func familySelector(policy metav1.DeletionPropagation) string {
+	return labels.SelectorFromSet(familyLabels(policy)).String()
+}
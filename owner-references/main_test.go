@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestRun(t *testing.T) {
+	// There is no garbage collector behind the fake clientsets - the
+	// dependents would never go. The in-process API server collects them
+	// within the DELETE request, with the same watch events.
+	server := fakeapiserver.NewServer()
+	defer server.Close()
+
+	client := kubernetes.NewForConfigOrDie(server.RESTConfig())
+
+	lc := lifecycle.New(prog)
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	run(ctx, client, "default")
+
+	lc.Finish()
+
+	configMaps, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := client.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 0 || len(secrets.Items) != 0 {
+		t.Errorf("expected nothing left, found %d ConfigMaps and %d Secrets", len(configMaps.Items), len(secrets.Items))
+	}
+}
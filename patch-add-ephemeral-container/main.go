@@ -3,40 +3,44 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"os"
-	"path"
+	"flag"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
-var (
-	ctx       = context.Background()
-	namespace = "default"
-)
+var namespace = "default"
 
 func main() {
 	// 0. Initialize the Kubernetes client.
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("patch-add-ephemeral-container", lifecycle.Pods)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "patch-add-ephemeral-container"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	run(ctx, client)
+}
+
+// run returns the patched Pod, so the offline test can inspect it.
+func run(ctx context.Context, client kubernetes.Interface) *corev1.Pod {
 	// 1. Create a pod.
 	pod, err := client.CoreV1().
 		Pods(namespace).
@@ -44,6 +48,7 @@ func main() {
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "patch-add-ephemeral-container",
 				Namespace: namespace,
+				Labels:    map[string]string{"example": "patch-add-ephemeral-container"},
 			},
 			Spec: corev1.PodSpec{
 				Containers: []corev1.Container{
@@ -58,9 +63,9 @@ func main() {
 	if err != nil {
 		panic(err.Error())
 	}
-	defer func() {
-		_ = client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-	}()
+	lifecycle.Track(ctx, "Pod", pod, func(ctx context.Context) error {
+		return client.CoreV1().Pods(namespace).Delete(ctx, "patch-add-ephemeral-container", metav1.DeleteOptions{})
+	})
 
 	// 2. Add an ephemeral container to the pod spec.
 	podWithEphemeralContainer := withDebugContainer(pod)
@@ -96,7 +101,13 @@ func main() {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Pod has %d ephemeral containers.\n", len(pod.Spec.EphemeralContainers))
+	klog.FromContext(ctx).Info(
+		"Patched Pod",
+		"pod", klog.KObj(pod),
+		"ephemeralContainers", len(pod.Spec.EphemeralContainers),
+		"resourceVersion", pod.ResourceVersion,
+	)
+	return pod
 }
 
 func withDebugContainer(pod *corev1.Pod) *corev1.Pod {
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRun(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	lc := lifecycle.New("patch-add-ephemeral-container")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	pod := run(ctx, client)
+	lc.Finish()
+
+	if len(pod.Spec.EphemeralContainers) != 1 {
+		t.Fatalf("expected 1 ephemeral container, got %d", len(pod.Spec.EphemeralContainers))
+	}
+	if name := pod.Spec.EphemeralContainers[0].Name; name != "debugger-123" {
+		t.Errorf("unexpected ephemeral container %q", name)
+	}
+
+	var patched bool
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" && action.GetSubresource() == "ephemeralcontainers" {
+			patched = true
+		}
+	}
+	if !patched {
+		t.Errorf("expected the ephemeralcontainers subresource to be patched, got %v", client.Actions())
+	}
+
+	list, err := client.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the Pod to be cleaned up, found %d Pods", len(list.Items))
+	}
+}
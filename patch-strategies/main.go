@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// The same logical change made with every patch type: add a sidecar
+// container next to the app container.
+var (
+	app     = corev1.Container{Name: "app", Image: "nginx:1.27-alpine"}
+	sidecar = corev1.Container{Name: "sidecar", Image: "busybox:1.36", Command: []string{"sleep", "infinity"}}
+)
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New("patch-strategies", lifecycle.Deployments)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "patch-strategies"), config)
+	defer lc.Finish()
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	run(ctx, client, "default", os.Stdout)
+}
+
+// run prints the patches and the patched containers to out. Every patch
+// goes to a Deployment of its own, created from the same template.
+func run(ctx context.Context, client kubernetes.Interface, namespace string, out io.Writer) {
+	jsonPatch(ctx, client, namespace, out)
+	mergePatch(ctx, client, namespace, out)
+	strategicMergePatch(ctx, client, namespace, out)
+	applyPatch(ctx, client, namespace, out)
+}
+
+// JSON Patch (RFC 6902) is a list of operations addressing the list items
+// by their index. The "test" operations make the patch fail with
+// 422 Unprocessable Entity if the object isn't what the patch expects -
+// here, if someone else has changed the spec since it was read (optimistic
+// locking) or the first container isn't the app.
+//
+// The lock is on the generation rather than the resourceVersion: the
+// deployment controller writes the status of a new Deployment right away,
+// which bumps the resourceVersion, but only spec changes bump the generation.
+func jsonPatch(ctx context.Context, client kubernetes.Interface, namespace string, out io.Writer) {
+	deployment := createDeployment(ctx, client, namespace)
+
+	patch := mustMarshal([]map[string]interface{}{
+		{"op": "test", "path": "/metadata/generation", "value": deployment.Generation},
+		{"op": "test", "path": "/spec/template/spec/containers/0/name", "value": app.Name},
+		{"op": "add", "path": "/spec/template/spec/containers/-", "value": sidecar},
+	})
+	patched := patchDeployment(ctx, client, deployment, types.JSONPatchType, patch, metav1.PatchOptions{}, out)
+	expectContainers(patched, app.Name, sidecar.Name)
+
+	// The same patch again - the generation has changed since.
+	_, err := client.AppsV1().Deployments(namespace).Patch(ctx, deployment.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if !errors.IsInvalid(err) {
+		panic(fmt.Sprintf("422 Unprocessable Entity expected, got %v", err))
+	}
+	fmt.Fprintf(out, "--- the same patch again:\n%s\n\n", err.Error())
+
+	deleteDeployment(ctx, client, deployment)
+}
+
+// JSON Merge Patch (RFC 7386) is a partial object, merged into the stored
+// one recursively. But lists are just values - a list in the patch
+// replaces the whole list, so the patch has to repeat the app container.
+func mergePatch(ctx context.Context, client kubernetes.Interface, namespace string, out io.Writer) {
+	deployment := createDeployment(ctx, client, namespace)
+
+	// A dry run shows what the patch with just the sidecar would do.
+	patch := mustMarshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []corev1.Container{sidecar},
+				},
+			},
+		},
+	})
+	patched := patchDeployment(ctx, client, deployment, types.MergePatchType, patch, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}}, out)
+	expectContainers(patched, sidecar.Name)
+
+	patch = mustMarshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []corev1.Container{app, sidecar},
+				},
+			},
+		},
+	})
+	patched = patchDeployment(ctx, client, deployment, types.MergePatchType, patch, metav1.PatchOptions{}, out)
+	expectContainers(patched, app.Name, sidecar.Name)
+
+	deleteDeployment(ctx, client, deployment)
+}
+
+// Strategic Merge Patch is a Kubernetes extension of the merge patch. The
+// Go types tell how to merge every list: the containers are merged by
+// their names (the patchMergeKey), so the patch needs just the sidecar.
+// Only the built-in types have the tags - there is no SMP for the custom
+// resources.
+//
+// The new items go first, unless the patch has a $setElementOrder directive
+// (strategicpatch.CreateTwoWayMergePatch() always adds one).
+func strategicMergePatch(ctx context.Context, client kubernetes.Interface, namespace string, out io.Writer) {
+	deployment := createDeployment(ctx, client, namespace)
+
+	patch := mustMarshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"$setElementOrder/containers": []map[string]string{{"name": app.Name}, {"name": sidecar.Name}},
+					"containers":                  []corev1.Container{sidecar},
+				},
+			},
+		},
+	})
+	patched := patchDeployment(ctx, client, deployment, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, out)
+	expectContainers(patched, app.Name, sidecar.Name)
+
+	deleteDeployment(ctx, client, deployment)
+}
+
+// Server-side apply sends the fields the field manager cares about. The
+// server merges the lists using the OpenAPI schema (x-kubernetes-list-type
+// and x-kubernetes-list-map-keys) - it works for the custom resources, too.
+// The sidecar is owned by the field manager from now on (see server-side-apply).
+func applyPatch(ctx context.Context, client kubernetes.Interface, namespace string, out io.Writer) {
+	deployment := createDeployment(ctx, client, namespace)
+
+	patch := mustMarshal(appsv1ac.Deployment(deployment.Name, namespace).
+		WithSpec(appsv1ac.DeploymentSpec().
+			WithTemplate(corev1ac.PodTemplateSpec().
+				WithSpec(corev1ac.PodSpec().
+					WithContainers(corev1ac.Container().
+						WithName(sidecar.Name).
+						WithImage(sidecar.Image).
+						WithCommand(sidecar.Command...),
+					),
+				),
+			),
+		),
+	)
+	patched := patchDeployment(ctx, client, deployment, types.ApplyPatchType, patch, metav1.PatchOptions{FieldManager: "patch-strategies"}, out)
+	expectContainers(patched, app.Name, sidecar.Name)
+
+	deleteDeployment(ctx, client, deployment)
+}
+
+func patchDeployment(
+	ctx context.Context,
+	client kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	pt types.PatchType,
+	patch []byte,
+	opts metav1.PatchOptions,
+	out io.Writer,
+) *appsv1.Deployment {
+	patched, err := client.
+		AppsV1().
+		Deployments(deployment.Namespace).
+		Patch(
+			ctx,
+			deployment.Name,
+			pt,
+			patch,
+			opts,
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info(
+		"Patched Deployment",
+		"deployment", klog.KObj(patched),
+		"patchType", pt,
+		"dryRun", len(opts.DryRun) > 0,
+		"resourceVersion", patched.ResourceVersion,
+	)
+
+	containers, err := yaml.Marshal(patched.Spec.Template.Spec.Containers)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	fmt.Fprintf(out, "=== %s", pt)
+	if len(opts.DryRun) > 0 {
+		fmt.Fprint(out, " (dry run)")
+	}
+	fmt.Fprintf(out, "\n%s\n--- containers:\n%s\n", patch, containers)
+
+	return patched
+}
+
+func createDeployment(ctx context.Context, client kubernetes.Interface, namespace string) *appsv1.Deployment {
+	labels := map[string]string{"app": "patch-strategies"}
+
+	// Nothing needs to run - the program only looks at the spec.
+	replicas := int32(0)
+
+	deployment, err := client.
+		AppsV1().
+		Deployments(namespace).
+		Create(
+			ctx,
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "patch-strategies-",
+					Namespace:    namespace,
+					Labels:       map[string]string{"example": "patch-strategies"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{app},
+						},
+					},
+				},
+			},
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info("Created Deployment", "deployment", klog.KObj(deployment), "resourceVersion", deployment.ResourceVersion)
+
+	lifecycle.Track(ctx, "Deployment", deployment, func(ctx context.Context) error {
+		return client.AppsV1().Deployments(namespace).Delete(ctx, deployment.Name, metav1.DeleteOptions{})
+	})
+	return deployment
+}
+
+func deleteDeployment(ctx context.Context, client kubernetes.Interface, deployment *appsv1.Deployment) {
+	err := client.
+		AppsV1().
+		Deployments(deployment.Namespace).
+		Delete(
+			ctx,
+			deployment.Name,
+			metav1.DeleteOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info("Deleted Deployment", "deployment", klog.KObj(deployment))
+}
+
+func expectContainers(deployment *appsv1.Deployment, names ...string) {
+	var got []string
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		got = append(got, c.Name)
+	}
+	if !reflect.DeepEqual(got, names) {
+		panic(fmt.Sprintf("Patched Deployment has unexpected containers %v, want %v", got, names))
+	}
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err.Error())
+	}
+	return data
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestRun(t *testing.T) {
+	// The fake clientsets don't fail the JSON Patch "test" operations with
+	// a status error, and merge apply patches the strategic way. The
+	// in-process API server does both like the real one.
+	server := fakeapiserver.NewServer()
+	defer server.Close()
+
+	client := kubernetes.NewForConfigOrDie(server.RESTConfig())
+
+	lc := lifecycle.New("patch-strategies")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	var out bytes.Buffer
+	run(ctx, client, "default", &out)
+
+	lc.Finish()
+
+	// Every patch type, and the merge patch once more for real.
+	for _, header := range []string{
+		"=== application/json-patch+json\n",
+		"=== application/merge-patch+json (dry run)\n",
+		"=== application/merge-patch+json\n",
+		"=== application/strategic-merge-patch+json\n",
+		"=== application/apply-patch+yaml\n",
+	} {
+		if !strings.Contains(out.String(), header) {
+			t.Errorf("expected %q in the output, got:\n%s", header, out.String())
+		}
+	}
+
+	list, err := client.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected no Deployments left, found %d", len(list.Items))
+	}
+}
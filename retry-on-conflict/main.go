@@ -2,16 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"math/rand"
-	"os"
-	"path"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
 )
 
 var (
@@ -20,42 +22,52 @@ var (
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	cfg, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("retry-on-conflict", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	cfg := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "retry-on-conflict"), cfg)
+	defer lc.Finish()
 
 	client := kubernetes.NewForConfigOrDie(cfg)
+	run(ctx, client)
+}
+
+func run(ctx context.Context, client kubernetes.Interface) {
+	logger := klog.FromContext(ctx)
+
 	desired := corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
+			Labels:    map[string]string{"example": "retry-on-conflict"},
 		},
 		Data: map[string]string{"foo": "bar"},
 	}
 
-	_, err = client.
+	created, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			&desired,
 			metav1.CreateOptions{},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
-	defer deleteConfigMap(client, name, namespace)
+	lifecycle.Track(ctx, "ConfigMap", created, func(ctx context.Context) error {
+		return deleteConfigMap(ctx, client, name, namespace)
+	})
 
 	firstTry := true
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// always fetch the new version from the api server
-		c, err := getConfigMap(client, name, namespace)
+		c, err := getConfigMap(ctx, client, name, namespace)
 		if err != nil {
 			return err
 		}
@@ -65,7 +77,7 @@ func main() {
 		// this is just for demonstration
 		if firstTry {
 			firstTry = false
-			simulateExternalUpdate(client, name, namespace)
+			simulateExternalUpdate(ctx, client, name, namespace)
 		}
 
 		// generate random int so we're always different
@@ -77,12 +89,13 @@ func main() {
 			CoreV1().
 			ConfigMaps(namespace).
 			Update(
-				context.Background(),
+				ctx,
 				c,
 				metav1.UpdateOptions{},
 			)
 		if err != nil {
-			fmt.Println(err)
+			// Conflicts are retried, anything else is fatal.
+			logger.Error(err, "Failed to update ConfigMap", "configMap", klog.KObj(c), "resourceVersion", c.ResourceVersion)
 		}
 		return err
 	})
@@ -90,40 +103,42 @@ func main() {
 		// ensure no other error type occurred
 		panic(err)
 	}
-	fmt.Println("Successfully updated ConfigMap")
+	logger.Info("Successfully updated ConfigMap", "configMap", klog.KRef(namespace, name))
 }
 
-func simulateExternalUpdate(k *kubernetes.Clientset, name, ns string) {
-	cm, err := getConfigMap(k, name, ns)
+func simulateExternalUpdate(ctx context.Context, k kubernetes.Interface, name, ns string) {
+	cm, err := getConfigMap(ctx, k, name, ns)
 	if err != nil {
 		panic(err)
 	}
 	cm.Data = map[string]string{
 		"external": "update",
 	}
-	_, err = k.CoreV1().
+	updated, err := k.CoreV1().
 		ConfigMaps(ns).
 		Update(
-			context.Background(),
+			ctx,
 			cm,
 			metav1.UpdateOptions{},
 		)
 	if err != nil {
 		panic(err)
 	}
+
+	klog.FromContext(ctx).Info("Simulated an external update", "configMap", klog.KObj(updated), "resourceVersion", updated.ResourceVersion)
 }
 
-func getConfigMap(k *kubernetes.Clientset, name, ns string) (*corev1.ConfigMap, error) {
+func getConfigMap(ctx context.Context, k kubernetes.Interface, name, ns string) (*corev1.ConfigMap, error) {
 	return k.
 		CoreV1().
 		ConfigMaps(ns).
 		Get(
-			context.Background(),
+			ctx,
 			name,
 			metav1.GetOptions{},
 		)
 }
 
-func deleteConfigMap(c *kubernetes.Clientset, name, ns string) {
-	c.CoreV1().ConfigMaps(ns).Delete(context.Background(), name, metav1.DeleteOptions{})
+func deleteConfigMap(ctx context.Context, c kubernetes.Interface, name, ns string) error {
+	return c.CoreV1().ConfigMaps(ns).Delete(ctx, name, metav1.DeleteOptions{})
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRun(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	lc := lifecycle.New("retry-on-conflict")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	run(ctx, client)
+	lc.Finish()
+
+	// The simulated external update, the update failing with a conflict,
+	// and the retried one.
+	var updates int
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 3 {
+		t.Errorf("expected 3 updates, got %d", updates)
+	}
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMap to be cleaned up, found %d ConfigMaps", len(list.Items))
+	}
+}
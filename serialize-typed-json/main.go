@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,6 +24,12 @@ func main() {
 	obj.Namespace = "default"
 	obj.Name = "my-configmap"
 
+	if err := serialize(os.Stdout, &obj); err != nil {
+		panic(err.Error())
+	}
+}
+
+func serialize(w io.Writer, obj *corev1.ConfigMap) error {
 	// Typed -> JSON (Option I)
 	//   - Serializer = Decoder + Encoder. Since we need only Encoder functionality
 	//     in this example, we can pass nil's instead of MetaFactory, Creater, and
@@ -38,11 +46,11 @@ func main() {
 	)
 
 	// Runtime.Encode() is just a helper function to invoke Encoder.Encode()
-	encoded, err := runtime.Encode(encoder, &obj)
+	encoded, err := runtime.Encode(encoder, obj)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Println("Serialized (option I)", string(encoded))
+	fmt.Fprintln(w, "Serialized (option I)", string(encoded))
 
 	// Typed -> JSON (Option II)
 	//   Actually, the implementation of Encoder.Encode() in the case of JSON
@@ -51,9 +59,9 @@ func main() {
 	//   See https://github.com/kubernetes/apimachinery/blob/73cb564852596cc976f3ead9e0f4678875af0cbf/pkg/runtime/serializer/json/json.go#L210-L234
 	encoded2, err := json.Marshal(obj)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Println("Serialized (option II)", string(encoded2))
+	fmt.Fprintln(w, "Serialized (option II)", string(encoded2))
 
 	// JSON -> Typed
 	//   - Serializer = Decoder + Encoder.
@@ -80,7 +88,9 @@ func main() {
 	// minor tweaks - see https://github.com/kubernetes-sigs/json for more.
 	decoded, err := runtime.Decode(decoder, encoded)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Printf("Deserialized %#v\n", decoded)
+	fmt.Fprintf(w, "Deserialized %#v\n", decoded)
+
+	return nil
 }
@@ -3,7 +3,10 @@ module github.com/iximiuz/client-go-examples/serialize-typed-yaml
 go 1.22.10
 
 require (
+	github.com/iximiuz/client-go-examples/golden v0.0.0
 	k8s.io/api v0.30.1
 	k8s.io/apimachinery v0.30.1
 	k8s.io/client-go v0.30.1
 )
+
+replace github.com/iximiuz/client-go-examples/golden => ../golden
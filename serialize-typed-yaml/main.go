@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,6 +26,12 @@ func main() {
 	obj.Namespace = "default"
 	obj.Name = "my-configmap"
 
+	if err := serialize(os.Stdout, &obj); err != nil {
+		panic(err.Error())
+	}
+}
+
+func serialize(w io.Writer, obj *corev1.ConfigMap) error {
 	// Serializer = Decoder + Encoder.
 	serializer := jsonserializer.NewSerializerWithOptions(
 		jsonserializer.DefaultMetaFactory, // jsonserializer.MetaFactory
@@ -38,16 +46,18 @@ func main() {
 
 	// Typed -> YAML
 	// Runtime.Encode() is just a helper function to invoke Encoder.Encode()
-	yaml, err := runtime.Encode(serializer, &obj)
+	yaml, err := runtime.Encode(serializer, obj)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Printf("Serialized:\n%s", string(yaml))
+	fmt.Fprintf(w, "Serialized:\n%s", string(yaml))
 
 	// YAML -> Typed (through JSON, actually)
 	decoded, err := runtime.Decode(serializer, yaml)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Printf("Deserialized: %#v\n", decoded)
+	fmt.Fprintf(w, "Deserialized: %#v\n", decoded)
+
+	return nil
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func init() {
+	// Decoded timestamps are in the local time zone.
+	time.Local = time.UTC
+}
+
+func TestSerialize(t *testing.T) {
+	typeMeta := metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"}
+
+	for _, tc := range []struct {
+		name string
+		obj  *corev1.ConfigMap
+	}{
+		{
+			name: "simple",
+			obj: &corev1.ConfigMap{
+				TypeMeta:   typeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap", Namespace: "default"},
+				Data:       map[string]string{"foo": "bar"},
+			},
+		},
+		{
+			name: "empty",
+			obj: &corev1.ConfigMap{
+				TypeMeta:   typeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap"},
+				Data:       map[string]string{},
+			},
+		},
+		{
+			name: "binary-data",
+			obj: &corev1.ConfigMap{
+				TypeMeta:   typeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap"},
+				BinaryData: map[string][]byte{"blob": {0x00, 0x01, 0xfe, 0xff}},
+			},
+		},
+		{
+			name: "creation-timestamp",
+			obj: &corev1.ConfigMap{
+				TypeMeta: typeMeta,
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "my-configmap",
+					CreationTimestamp: metav1.Date(2024, time.February, 29, 12, 30, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "unicode",
+			obj: &corev1.ConfigMap{
+				TypeMeta:   typeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap"},
+				Data:       map[string]string{"ключ": "значение", "emoji": "🙂", "html": "<b>&</b>"},
+			},
+		},
+		{
+			// Strings that would be something else if left unquoted.
+			name: "yaml-lookalikes",
+			obj: &corev1.ConfigMap{
+				TypeMeta:   typeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap"},
+				Data:       map[string]string{"bool": "yes", "number": "012", "null": "~", "multiline": "a\nb\n"},
+			},
+		},
+		{
+			// Serializes fine, but can't be decoded back.
+			name: "no-type-meta",
+			obj: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-configmap"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := serialize(&out, tc.obj); err != nil {
+				fmt.Fprintf(&out, "error: %v\n", err)
+			}
+
+			golden.Assert(t, tc.name, out.Bytes())
+		})
+	}
+}
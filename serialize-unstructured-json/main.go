@@ -1,7 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -24,6 +27,12 @@ func main() {
 		},
 	}
 
+	if err := serialize(os.Stdout, &uConfigMap); err != nil {
+		panic(err.Error())
+	}
+}
+
+func serialize(w io.Writer, uConfigMap *unstructured.Unstructured) error {
 	// Unstructured -> JSON (Option I)
 	//   - Despite the name, `UnstructuredJSONScheme` is not a scheme but a codec
 	//   - runtime.Encode() is just a helper function to invoke UnstructuredJSONScheme.Encode()
@@ -31,22 +40,25 @@ func main() {
 	//     either a single object, a list, or an unknown runtime object, so some amount of
 	//     preprocessing is required before passing the data to json.Marshal()
 	//   - Usage example: dynamic client (client-go/dynamic.Interface)
-	bytes, err := runtime.Encode(unstructured.UnstructuredJSONScheme, &uConfigMap)
-	fmt.Println("Serialized (option I)", string(bytes))
+	bytes, err := runtime.Encode(unstructured.UnstructuredJSONScheme, uConfigMap)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Serialized (option I)", string(bytes))
 
 	// Unstructured -> JSON (Option II)
 	//   - This is just a handy shortcut for the above code.
 	bytes, err = uConfigMap.MarshalJSON()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
-	fmt.Println("Serialized (option II)", string(bytes))
+	fmt.Fprintln(w, "Serialized (option II)", string(bytes))
 
 	// JSON -> Unstructured (Option I)
 	//   - Usage example: dynamic client (client-go/dynamic.Interface)
 	obj1, err := runtime.Decode(unstructured.UnstructuredJSONScheme, bytes)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
 	// JSON -> Unstructured (Option II)
@@ -54,9 +66,12 @@ func main() {
 	obj2 := &unstructured.Unstructured{}
 	err = obj2.UnmarshalJSON(bytes)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 	if !reflect.DeepEqual(obj1, obj2) {
-		panic("Unexpected configmap data")
+		return errors.New("unexpected configmap data")
 	}
+	fmt.Fprintf(w, "Deserialized %#v\n", obj2.Object)
+
+	return nil
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func TestSerialize(t *testing.T) {
+	configMap := func(metadata, rest map[string]interface{}) *unstructured.Unstructured {
+		obj := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   metadata,
+		}
+		for k, v := range rest {
+			obj[k] = v
+		}
+		return &unstructured.Unstructured{Object: obj}
+	}
+
+	for _, tc := range []struct {
+		name string
+		obj  *unstructured.Unstructured
+	}{
+		{
+			name: "simple",
+			obj: configMap(
+				map[string]interface{}{"creationTimestamp": nil, "namespace": "default", "name": "my-configmap"},
+				map[string]interface{}{"data": map[string]interface{}{"foo": "bar"}},
+			),
+		},
+		{
+			name: "empty",
+			obj:  configMap(map[string]interface{}{"name": "my-configmap"}, nil),
+		},
+		{
+			// Unstructured objects keep binaryData base64-encoded.
+			name: "binary-data",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap"},
+				map[string]interface{}{"binaryData": map[string]interface{}{"blob": "AAH+/w=="}},
+			),
+		},
+		{
+			name: "creation-timestamp",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap", "creationTimestamp": "2024-02-29T12:30:00Z"},
+				nil,
+			),
+		},
+		{
+			name: "unicode",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap"},
+				map[string]interface{}{"data": map[string]interface{}{"ключ": "значение", "emoji": "🙂", "html": "<b>&</b>"}},
+			),
+		},
+		{
+			// Nothing checks the values against the ConfigMap schema. Note how
+			// the numbers come back as int64 and float64.
+			name: "non-string-values",
+			obj: configMap(
+				map[string]interface{}{"name": "my-configmap"},
+				map[string]interface{}{"data": map[string]interface{}{"int": int64(42), "float": 0.5, "bool": true}},
+			),
+		},
+		{
+			name: "no-kind",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"metadata":   map[string]interface{}{"name": "my-configmap"},
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := serialize(&out, tc.obj); err != nil {
+				fmt.Fprintf(&out, "error: %v\n", err)
+			}
+
+			golden.Assert(t, tc.name, out.Bytes())
+		})
+	}
+}
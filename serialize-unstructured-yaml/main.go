@@ -1,6 +1,11 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -18,23 +23,33 @@ metadata:
   namespace: default
 `
 
-	// YAML -> Unstructured (through JSON)
-	jConfigMap, err := yaml.ToJSON([]byte(yConfigMap))
+	uConfigMap, err := deserialize(os.Stdout, []byte(yConfigMap))
 	if err != nil {
 		panic(err.Error())
 	}
 
+	if uConfigMap.GetName() != "my-configmap" {
+		panic("Unexpected configmap data")
+	}
+}
+
+func deserialize(w io.Writer, yConfigMap []byte) (*unstructured.Unstructured, error) {
+	// YAML -> Unstructured (through JSON)
+	jConfigMap, err := yaml.ToJSON(yConfigMap)
+	if err != nil {
+		return nil, err
+	}
+
 	object, err := runtime.Decode(unstructured.UnstructuredJSONScheme, jConfigMap)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
 	uConfigMap, ok := object.(*unstructured.Unstructured)
 	if !ok {
-		panic("unstructured.Unstructured expected")
+		return nil, errors.New("unstructured.Unstructured expected")
 	}
+	fmt.Fprintf(w, "Deserialized %#v\n", uConfigMap.Object)
 
-	if uConfigMap.GetName() != "my-configmap" {
-		panic("Unexpected configmap data")
-	}
+	return uConfigMap, nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/golden"
+)
+
+func TestDeserialize(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "simple",
+			yaml: `---
+apiVersion: v1
+data:
+  foo: bar
+kind: ConfigMap
+metadata:
+  creationTimestamp:
+  name: my-configmap
+  namespace: default
+`,
+		},
+		{
+			name: "empty",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+data: {}
+`,
+		},
+		{
+			name: "binary-data",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+binaryData:
+  blob: AAH+/w==
+`,
+		},
+		{
+			name: "creation-timestamp",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+  creationTimestamp: "2024-02-29T12:30:00Z"
+`,
+		},
+		{
+			name: "unicode",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+data:
+  ключ: значение
+  emoji: 🙂
+  html: <b>&</b>
+`,
+		},
+		{
+			// Unquoted, these aren't strings anymore (and wouldn't be accepted
+			// by the API server).
+			name: "unquoted-values",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-configmap
+data:
+  bool: yes
+  number: 012
+  float: 1.5
+  nothing: ~
+  quoted: "yes"
+`,
+		},
+		{
+			name: "list",
+			yaml: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: my-configmap
+`,
+		},
+		{
+			name: "invalid",
+			yaml: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+	name: my-configmap
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if _, err := deserialize(&out, []byte(tc.yaml)); err != nil {
+				fmt.Fprintf(&out, "error: %v\n", err)
+			}
+
+			golden.Assert(t, tc.name, out.Bytes())
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Two actors sharing one ConfigMap: the deployer owns the config as
+	// a whole, the tuner adjusts a couple of keys on top of it.
+	deployer = "server-side-apply-deployer"
+	tuner    = "server-side-apply-tuner"
+)
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New("server-side-apply", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "server-side-apply"), config)
+	defer lc.Finish()
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	run(ctx, client, dynamicClient, "default")
+}
+
+// run is separated from main() to be testable against fakeapiserver - the
+// fake clientsets treat apply patches as strategic merge patches, with no
+// field ownership (and hence no conflicts or removals) involved.
+func run(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string) {
+	logger := klog.FromContext(ctx)
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	// Apply is an upsert: there is no generateName, the name is a part
+	// of the desired state. And the FieldManager is mandatory.
+	name := "server-side-apply-" + rand.String(6)
+
+	// The deployer applies its desired state - the ConfigMap gets created.
+	cm, err := configMaps.Apply(
+		ctx,
+		deployerConfig(name, namespace, map[string]string{"color": "blue", "size": "small", "mode": "debug"}),
+		metav1.ApplyOptions{FieldManager: deployer},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	logApplied(ctx, deployer, cm)
+
+	// Deleted below, unless the program panics or gets interrupted midway.
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return configMaps.Delete(ctx, name, metav1.DeleteOptions{})
+	})
+
+	expectData(cm, map[string]string{"color": "blue", "size": "small", "mode": "debug"})
+
+	// The tuner wants a different color. But .data.color is owned by the
+	// deployer, so the apply fails with 409 Conflict naming the field and
+	// its manager. (Applying the same value would make them co-owners.)
+	_, err = configMaps.Apply(
+		ctx,
+		corev1ac.ConfigMap(name, namespace).WithData(map[string]string{"color": "red"}),
+		metav1.ApplyOptions{FieldManager: tuner},
+	)
+	if !errors.IsConflict(err) {
+		panic(fmt.Sprintf("Conflict expected, got %v", err))
+	}
+	logger.Info("Apply conflicted", "fieldManager", tuner, "reason", err.Error())
+
+	// Force takes the field over - the deployer isn't its owner anymore.
+	cm, err = configMaps.Apply(
+		ctx,
+		corev1ac.ConfigMap(name, namespace).WithData(map[string]string{"color": "red"}),
+		metav1.ApplyOptions{FieldManager: tuner, Force: true},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	logApplied(ctx, tuner, cm)
+
+	expectData(cm, map[string]string{"color": "red", "size": "small", "mode": "debug"})
+
+	// The deployer drops "mode" from its desired state (and "color", as it
+	// would conflict again). The fields a manager stops applying are
+	// removed, as long as nobody else owns them - "color" is the tuner's
+	// now, so it stays.
+	cm, err = configMaps.Apply(
+		ctx,
+		deployerConfig(name, namespace, map[string]string{"size": "small"}),
+		metav1.ApplyOptions{FieldManager: deployer},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	logApplied(ctx, deployer, cm)
+
+	expectData(cm, map[string]string{"color": "red", "size": "small"})
+
+	// Read-modify-apply. Applying just the new key would remove "color" -
+	// the tuner wouldn't be applying it anymore. ExtractConfigMap() turns
+	// the live object into an apply configuration with only the fields
+	// the given manager owns, so the change can be made on top of them.
+	live, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	desired, err := corev1ac.ExtractConfigMap(live, tuner)
+	if err != nil {
+		panic(err.Error())
+	}
+	if !reflect.DeepEqual(desired.Data, map[string]string{"color": "red"}) {
+		panic(fmt.Sprintf("Extracted apply configuration has unexpected data %v", desired.Data))
+	}
+
+	cm, err = configMaps.Apply(
+		ctx,
+		desired.WithData(map[string]string{"timeout": "30s"}),
+		metav1.ApplyOptions{FieldManager: tuner},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	logApplied(ctx, tuner, cm)
+
+	expectData(cm, map[string]string{"color": "red", "size": "small", "timeout": "30s"})
+
+	// The same with the dynamic client - the desired state is an unstructured
+	// object, and the ownership is the same no matter which client applies.
+	applied, err := dynamicClient.
+		Resource(corev1.SchemeGroupVersion.WithResource("configmaps")).
+		Namespace(namespace).
+		Apply(
+			ctx,
+			name,
+			deployerObject(name, namespace, map[string]interface{}{"size": "large"}),
+			metav1.ApplyOptions{FieldManager: deployer},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	cm = &corev1.ConfigMap{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, cm); err != nil {
+		panic(err.Error())
+	}
+	logApplied(ctx, deployer, cm)
+
+	expectData(cm, map[string]string{"color": "red", "size": "large", "timeout": "30s"})
+
+	// Delete
+	err = configMaps.Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	logger.Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
+}
+
+// deployerConfig is the deployer's desired state. The apply configuration
+// is always the complete intent of the manager - the label, too, would be
+// removed if the deployer stopped applying it.
+func deployerConfig(name, namespace string, data map[string]string) *corev1ac.ConfigMapApplyConfiguration {
+	return corev1ac.ConfigMap(name, namespace).
+		WithLabels(map[string]string{"example": "server-side-apply"}).
+		WithData(data)
+}
+
+// deployerObject is deployerConfig() for the dynamic client. Unlike the
+// typed apply configurations, apiVersion and kind have to be set explicitly.
+func deployerObject(name, namespace string, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					"example": "server-side-apply",
+				},
+			},
+			"data": data,
+		},
+	}
+}
+
+func logApplied(ctx context.Context, manager string, cm *corev1.ConfigMap) {
+	klog.FromContext(ctx).Info(
+		"Applied ConfigMap",
+		"configMap", klog.KObj(cm),
+		"fieldManager", manager,
+		"resourceVersion", cm.GetResourceVersion(),
+		"data", cm.Data,
+		"managers", managers(cm),
+	)
+}
+
+// managers lists who owns the fields of the object and how they got
+// the ownership (Apply or Update).
+func managers(obj metav1.Object) []string {
+	var list []string
+	for _, entry := range obj.GetManagedFields() {
+		list = append(list, fmt.Sprintf("%s (%s)", entry.Manager, entry.Operation))
+	}
+	return list
+}
+
+func expectData(cm *corev1.ConfigMap, want map[string]string) {
+	if !reflect.DeepEqual(cm.Data, want) {
+		panic(fmt.Sprintf("Applied ConfigMap has unexpected data %v, want %v", cm.Data, want))
+	}
+}
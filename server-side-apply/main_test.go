@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestRun(t *testing.T) {
+	// Unlike the fake clientsets, fakeapiserver tracks the field ownership,
+	// so the conflict, the takeover, and the removal by omission are real.
+	server := fakeapiserver.NewServer()
+	defer server.Close()
+
+	config := server.RESTConfig()
+	client := kubernetes.NewForConfigOrDie(config)
+
+	lc := lifecycle.New("server-side-apply")
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	run(ctx, client, dynamic.NewForConfigOrDie(config), "default")
+
+	// Nothing to clean up - the program deletes the ConfigMap itself.
+	lc.Finish()
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected no ConfigMaps left, found %d", len(list.Items))
+	}
+}
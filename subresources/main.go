@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const prog = "subresources"
+
+// The condition the program adds to the Deployment's status.
+const conditionType = "SubresourcesExample"
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New(prog, lifecycle.Deployments, lifecycle.Pods, lifecycle.PodDisruptionBudgets)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), prog), config)
+	defer lc.Finish()
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	run(ctx, client, dynamicClient, "default")
+}
+
+// run works with the Deployment's scale and status, then with the Pod's
+// binding and eviction - first with the typed clientset, which has a method
+// per subresource, then with the dynamic client, which takes the name of
+// the subresource as the last (variadic) argument of the usual methods.
+func run(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string) {
+	deployment := createDeployment(ctx, client, namespace)
+
+	scaleDeployment(ctx, client, deployment)
+	updateDeploymentStatus(ctx, client, deployment)
+	scaleDeploymentDynamic(ctx, dynamicClient, deployment)
+
+	err := client.AppsV1().Deployments(namespace).Delete(ctx, deployment.Name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Deleted Deployment", "deployment", klog.KObj(deployment))
+
+	pod := createPod(ctx, client, namespace)
+
+	bindPod(ctx, client, pod)
+	evictPod(ctx, client, pod)
+	evictPodDynamic(ctx, client, dynamicClient, pod)
+}
+
+// The scale subresource is the replicas of the Deployment (and of any other
+// scalable resource) in the same autoscaling/v1 Scale shape - that's what
+// kubectl scale and the HorizontalPodAutoscaler use. Its resourceVersion is
+// the Deployment's, so the update can conflict like any other.
+func scaleDeployment(ctx context.Context, client kubernetes.Interface, deployment *appsv1.Deployment) {
+	deployments := client.AppsV1().Deployments(deployment.Namespace)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := deployments.GetScale(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		scale.Spec.Replicas = 2
+		scale, err = deployments.UpdateScale(ctx, deployment.Name, scale, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+
+		klog.FromContext(ctx).Info(
+			"Scaled Deployment",
+			"deployment", klog.KObj(deployment),
+			"replicas", scale.Spec.Replicas,
+			"selector", scale.Status.Selector,
+			"resourceVersion", scale.ResourceVersion,
+		)
+		return nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	expectReplicas(ctx, client, deployment, 2)
+}
+
+// The status subresource is how the controllers report the observed state.
+// Writing to it, the spec changes are ignored (and writing to the main
+// resource, the status changes are). Against a real cluster, the
+// deployment controller updates the status, too - hence the retries.
+func updateDeploymentStatus(ctx context.Context, client kubernetes.Interface, deployment *appsv1.Deployment) {
+	deployments := client.AppsV1().Deployments(deployment.Namespace)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := deployments.Get(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		replicas := int32(5)
+		current.Spec.Replicas = &replicas
+		current.Status.Conditions = append(current.Status.Conditions, appsv1.DeploymentCondition{
+			Type:               conditionType,
+			Status:             corev1.ConditionTrue,
+			Reason:             "StatusUpdated",
+			Message:            "Set through the status subresource",
+			LastUpdateTime:     metav1.Now(),
+			LastTransitionTime: metav1.Now(),
+		})
+
+		updated, err := deployments.UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+
+		klog.FromContext(ctx).Info(
+			"Updated Deployment status",
+			"deployment", klog.KObj(updated),
+			"replicas", *updated.Spec.Replicas,
+			"conditions", len(updated.Status.Conditions),
+			"resourceVersion", updated.ResourceVersion,
+		)
+		return nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	expectReplicas(ctx, client, deployment, 2)
+}
+
+// The same subresources through the dynamic client. The Scale comes back
+// as an unstructured object of its own kind - not a Deployment.
+func scaleDeploymentDynamic(ctx context.Context, dynamicClient dynamic.Interface, deployment *appsv1.Deployment) {
+	deployments := dynamicClient.
+		Resource(appsv1.SchemeGroupVersion.WithResource("deployments")).
+		Namespace(deployment.Namespace)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		scale, err := deployments.Get(ctx, deployment.Name, metav1.GetOptions{}, "scale")
+		if err != nil {
+			return err
+		}
+
+		if err := unstructured.SetNestedField(scale.Object, int64(1), "spec", "replicas"); err != nil {
+			return err
+		}
+		scale, err = deployments.Update(ctx, scale, metav1.UpdateOptions{}, "scale")
+		if err != nil {
+			return err
+		}
+
+		replicas, _, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+		klog.FromContext(ctx).Info(
+			"Scaled Deployment",
+			"deployment", klog.KObj(deployment),
+			"kind", scale.GetKind(),
+			"replicas", replicas,
+			"resourceVersion", scale.GetResourceVersion(),
+		)
+		return nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// Reading the status subresource returns the whole object.
+	obj, err := deployments.Get(ctx, deployment.Name, metav1.GetOptions{}, "status")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	found := false
+	for _, c := range conditions {
+		if typ, _, _ := unstructured.NestedString(c.(map[string]interface{}), "type"); typ == conditionType {
+			found = true
+		}
+	}
+	if !found {
+		panic(fmt.Sprintf("Deployment status has no %s condition", conditionType))
+	}
+	klog.FromContext(ctx).Info("Read Deployment status", "deployment", klog.KObj(obj), "conditions", len(conditions))
+}
+
+// The binding subresource is what the scheduler creates to assign a Pod
+// to a Node. The Pod names a scheduler that doesn't exist, so nobody but
+// the program binds it.
+func bindPod(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) {
+	pods := client.CoreV1().Pods(pod.Namespace)
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	if len(nodes.Items) == 0 {
+		panic("No Nodes to bind the Pod to")
+	}
+	node := nodes.Items[0].Name
+
+	err = pods.Bind(
+		ctx,
+		&corev1.Binding{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name},
+			Target:     corev1.ObjectReference{Kind: "Node", Name: node},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Bound Pod", "pod", klog.KObj(pod), "node", node)
+
+	// Only the healthy Pods count against the disruption budget.
+	err = wait.PollUntilContextTimeout(ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range current.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Pod is ready", "pod", klog.KObj(pod))
+}
+
+// The eviction subresource is a delete that respects the Pod's
+// PodDisruptionBudget. The budget below needs the only Pod it covers,
+// so the eviction is refused with 429 Too Many Requests - kubectl drain
+// keeps retrying such evictions until the budget allows them.
+func evictPod(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) {
+	budgets := client.PolicyV1().PodDisruptionBudgets(pod.Namespace)
+
+	minAvailable := intstr.FromInt32(1)
+	pdb, err := budgets.Create(
+		ctx,
+		&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: prog + "-",
+				Namespace:    pod.Namespace,
+				Labels:       map[string]string{lifecycle.Label: prog},
+			},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+				Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": pod.Labels["app"]}},
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Created PodDisruptionBudget", "pdb", klog.KObj(pdb), "resourceVersion", pdb.ResourceVersion)
+
+	lifecycle.Track(ctx, "PodDisruptionBudget", pdb, func(ctx context.Context) error {
+		return budgets.Delete(ctx, pdb.Name, metav1.DeleteOptions{})
+	})
+
+	err = client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	})
+	if !errors.IsTooManyRequests(err) {
+		panic(fmt.Sprintf("429 Too Many Requests expected, got %v", err))
+	}
+	klog.FromContext(ctx).Info("Eviction refused", "pod", klog.KObj(pod), "reason", err.Error())
+
+	if err := budgets.Delete(ctx, pdb.Name, metav1.DeleteOptions{}); err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Deleted PodDisruptionBudget", "pdb", klog.KObj(pdb))
+}
+
+// With the budget gone, the eviction goes through - this time created with
+// the dynamic client. The Eviction's name has to be the Pod's.
+func evictPodDynamic(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, pod *corev1.Pod) {
+	eviction := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": policyv1.SchemeGroupVersion.String(),
+			"kind":       "Eviction",
+			"metadata": map[string]interface{}{
+				"name":      pod.Name,
+				"namespace": pod.Namespace,
+			},
+		},
+	}
+
+	_, err := dynamicClient.
+		Resource(corev1.SchemeGroupVersion.WithResource("pods")).
+		Namespace(pod.Namespace).
+		Create(ctx, eviction, metav1.CreateOptions{}, "eviction")
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Evicted Pod", "pod", klog.KObj(pod))
+
+	// An evicted Pod is deleted gracefully - it takes a while to be gone.
+	err = wait.PollUntilContextTimeout(ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+		_, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Pod is gone", "pod", klog.KObj(pod))
+}
+
+func createDeployment(ctx context.Context, client kubernetes.Interface, namespace string) *appsv1.Deployment {
+	labels := map[string]string{"app": prog + "-scaled"}
+	replicas := int32(0)
+
+	deployment, err := client.
+		AppsV1().
+		Deployments(namespace).
+		Create(
+			ctx,
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: prog + "-",
+					Namespace:    namespace,
+					Labels:       map[string]string{lifecycle.Label: prog},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "app", Image: "registry.k8s.io/pause:3.9"}},
+						},
+					},
+				},
+			},
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Created Deployment", "deployment", klog.KObj(deployment), "resourceVersion", deployment.ResourceVersion)
+
+	lifecycle.Track(ctx, "Deployment", deployment, func(ctx context.Context) error {
+		return client.AppsV1().Deployments(namespace).Delete(ctx, deployment.Name, metav1.DeleteOptions{})
+	})
+	return deployment
+}
+
+func createPod(ctx context.Context, client kubernetes.Interface, namespace string) *corev1.Pod {
+	pod, err := client.
+		CoreV1().
+		Pods(namespace).
+		Create(
+			ctx,
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: prog + "-",
+					Namespace:    namespace,
+					Labels:       map[string]string{lifecycle.Label: prog, "app": prog + "-evicted"},
+				},
+				Spec: corev1.PodSpec{
+					SchedulerName: prog,
+					Containers:    []corev1.Container{{Name: "app", Image: "registry.k8s.io/pause:3.9"}},
+				},
+			},
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		panic(err.Error())
+	}
+	klog.FromContext(ctx).Info("Created Pod", "pod", klog.KObj(pod), "resourceVersion", pod.ResourceVersion)
+
+	lifecycle.Track(ctx, "Pod", pod, func(ctx context.Context) error {
+		return client.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+	})
+	return pod
+}
+
+func expectReplicas(ctx context.Context, client kubernetes.Interface, deployment *appsv1.Deployment, want int32) {
+	current, err := client.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+	if got := *current.Spec.Replicas; got != want {
+		panic(fmt.Sprintf("Deployment has %d replicas, want %d", got, want))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestRun(t *testing.T) {
+	// The fake clientsets can't turn a Scale into an update of the
+	// Deployment, or an Eviction into a delete of the Pod (they ignore it).
+	server := fakeapiserver.NewServer()
+	defer server.Close()
+
+	config := server.RESTConfig()
+	client := kubernetes.NewForConfigOrDie(config)
+
+	lc := lifecycle.New(prog)
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	run(ctx, client, dynamic.NewForConfigOrDie(config), "default")
+
+	lc.Finish()
+
+	deployments, err := client.AppsV1().Deployments("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods, err := client.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	budgets, err := client.PolicyV1().PodDisruptionBudgets("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(deployments.Items) + len(pods.Items) + len(budgets.Items); n != 0 {
+		t.Errorf("expected nothing left, found %d Deployments, %d Pods, and %d PodDisruptionBudgets",
+			len(deployments.Items), len(pods.Items), len(budgets.Items))
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/iximiuz/client-go-examples/timeouts-throttling
+
+go 1.22.10
+
+require (
+	github.com/go-logr/logr v1.4.1
+	github.com/iximiuz/client-go-examples/fakeapiserver v0.0.0
+	github.com/iximiuz/client-go-examples/logging v0.0.0
+	k8s.io/apimachinery v0.30.1
+	k8s.io/client-go v0.30.1
+	k8s.io/klog/v2 v2.120.1
+)
+
+replace github.com/iximiuz/client-go-examples/fakeapiserver => ../fakeapiserver
+
+replace github.com/iximiuz/client-go-examples/logging => ../logging
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const prog = "timeouts-throttling"
+
+// How many requests every throttling configuration sends.
+const requests = 20
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx := logs.NewContext(context.Background(), prog)
+
+	// The pacing is measured against a local API server stand-in - a real
+	// cluster would add its own (and varying) latency, and its API Priority
+	// and Fairness could throttle the requests, too.
+	server := startServer()
+	defer server.Close()
+
+	run(ctx, server, os.Stdout)
+}
+
+func run(ctx context.Context, server *server, out io.Writer) {
+	server.latency.Store(int64(20 * time.Millisecond))
+
+	// Every clientset gets a token bucket rate limiter, unless the config
+	// has one already. QPS (the refill rate) and Burst (the bucket size)
+	// are 5 and 10 when not set.
+	throttling(ctx, server, out, "QPS=5 Burst=10 (the defaults)", func(config *rest.Config) {})
+
+	throttling(ctx, server, out, "QPS=50 Burst=100", func(config *rest.Config) {
+		config.QPS = 50
+		config.Burst = 100
+	})
+
+	// A negative QPS disables the client-side rate limiting altogether.
+	throttling(ctx, server, out, "QPS=-1 (no rate limiter)", func(config *rest.Config) {
+		config.QPS = -1
+	})
+
+	// QPS and Burst are ignored when the config comes with a RateLimiter.
+	// Sharing one between the configs makes several clients stay within
+	// one budget.
+	throttling(ctx, server, out, "RateLimiter=paced(150ms)", func(config *rest.Config) {
+		config.RateLimiter = newPacedLimiter(150 * time.Millisecond)
+	})
+
+	server.latency.Store(int64(200 * time.Millisecond))
+	timeouts(ctx, server, out)
+}
+
+// throttling sends the requests one by one, with the config tweaked, and
+// prints the histogram of their latencies. The requests waiting for the
+// rate limiter longer than 50ms are logged by client-go (-v=3):
+//
+//	"Waited for 188.6ms due to client-side throttling, not priority and fairness, request: GET:https://..."
+func throttling(ctx context.Context, server *server, out io.Writer, name string, tweak func(*rest.Config)) {
+	config := server.RESTConfig()
+	tweak(config)
+	client := kubernetes.NewForConfigOrDie(config)
+
+	var latencies []time.Duration
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		reqStart := time.Now()
+		if _, err := client.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{}); err != nil {
+			panic(err.Error())
+		}
+		latencies = append(latencies, time.Since(reqStart))
+	}
+	elapsed := time.Since(start)
+
+	fmt.Fprintf(out, "=== %s\n", name)
+	printHistogram(out, latencies)
+	fmt.Fprintf(out, "%d requests in %v (%.1f req/s)\n\n",
+		requests, elapsed.Round(time.Millisecond), float64(requests)/elapsed.Seconds())
+}
+
+// timeouts shows the ways a request can time out - the server takes 200ms
+// to respond to any of them.
+func timeouts(ctx context.Context, server *server, out io.Writer) {
+	// rest.Config.Timeout becomes the http.Client's Timeout (and the
+	// ?timeout= parameter telling the API server to give up, too). It
+	// applies to every request of the client, watches included - so the
+	// watching clients better go without it.
+	config := server.RESTConfig()
+	config.Timeout = 100 * time.Millisecond
+	timeout(ctx, out, "rest.Config.Timeout=100ms", kubernetes.NewForConfigOrDie(config))
+
+	// A context deadline applies to a single call, including the time it
+	// waits for the rate limiter, and the retries.
+	client := kubernetes.NewForConfigOrDie(server.RESTConfig())
+	callCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	timeout(callCtx, out, "context.WithTimeout(100ms)", client)
+
+	// The token bucket knows it can't let the request through before the
+	// deadline and fails it right away, without sending anything.
+	config = server.RESTConfig()
+	config.QPS = 1
+	config.Burst = 1
+	client = kubernetes.NewForConfigOrDie(config)
+	if _, err := client.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{}); err != nil {
+		panic(err.Error())
+	}
+	callCtx, cancel = context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	timeout(callCtx, out, "context.WithTimeout(500ms), QPS=1 Burst=1 (exhausted)", client)
+}
+
+func timeout(ctx context.Context, out io.Writer, name string, client kubernetes.Interface) {
+	start := time.Now()
+	_, err := client.CoreV1().ConfigMaps("default").List(ctx, metav1.ListOptions{})
+	elapsed := time.Since(start)
+	if err == nil {
+		panic(fmt.Sprintf("%s: expected the request to time out", name))
+	}
+
+	fmt.Fprintf(out, "=== %s\n", name)
+	fmt.Fprintf(out, "failed after %v: %v\n", elapsed.Round(10*time.Millisecond), err)
+	fmt.Fprintf(out, "errors.Is(err, context.DeadlineExceeded): %v\n\n", errors.Is(err, context.DeadlineExceeded))
+}
+
+// Upper bounds of the histogram buckets; the last one catches the rest.
+var buckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+func printHistogram(out io.Writer, latencies []time.Duration) {
+	counts := make([]int, len(buckets)+1)
+	for _, l := range latencies {
+		i := 0
+		for i < len(buckets) && l >= buckets[i] {
+			i++
+		}
+		counts[i]++
+	}
+
+	for i, count := range counts {
+		label := "+Inf"
+		if i < len(buckets) {
+			label = "< " + buckets[i].String()
+		}
+		fmt.Fprintf(out, "%8s %3d %s\n", label, count, strings.Repeat("#", count))
+	}
+}
+
+// pacedLimiter is a flowcontrol.RateLimiter letting a request through
+// every interval - evenly spaced, without the bursts of a token bucket.
+type pacedLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // the earliest time the next request can go
+}
+
+var _ flowcontrol.RateLimiter = &pacedLimiter{}
+
+func newPacedLimiter(interval time.Duration) *pacedLimiter {
+	return &pacedLimiter{interval: interval}
+}
+
+func (l *pacedLimiter) TryAccept() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.next) {
+		return false
+	}
+	l.next = now.Add(l.interval)
+	return true
+}
+
+func (l *pacedLimiter) Accept() {
+	l.Wait(context.Background())
+}
+
+// Wait takes the next free slot and waits for it. Like the token bucket,
+// it fails right away if the slot is past the context's deadline.
+func (l *pacedLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	slot := time.Now()
+	if slot.Before(l.next) {
+		slot = l.next
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(slot) {
+		l.mu.Unlock()
+		return fmt.Errorf("paced limiter: the next slot in %v would exceed the context deadline", time.Until(slot).Round(time.Millisecond))
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *pacedLimiter) Stop() {}
+
+func (l *pacedLimiter) QPS() float32 {
+	return float32(time.Second) / float32(l.interval)
+}
+
+// server is fakeapiserver taking (at least) latency to respond to any
+// request. The client giving up cuts the wait short.
+type server struct {
+	*fakeapiserver.Server
+
+	latency atomic.Int64
+}
+
+func startServer() *server {
+	s := &server{Server: fakeapiserver.New()}
+
+	handler := s.Config.Handler
+	s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Duration(s.latency.Load())):
+		case <-r.Context().Done():
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	s.StartTLS()
+	return s
+}
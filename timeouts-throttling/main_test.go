@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/klog/v2"
+)
+
+func TestRun(t *testing.T) {
+	// client-go logs the throttled requests with klog's global logger.
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("v", "3"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("v", "0")
+
+	var (
+		mu        sync.Mutex
+		throttled int
+	)
+	klog.SetLogger(funcr.New(func(prefix, args string) {
+		if strings.Contains(args, "due to client-side throttling") {
+			mu.Lock()
+			defer mu.Unlock()
+			throttled++
+		}
+	}, funcr.Options{Verbosity: 3}))
+	defer klog.ClearLogger()
+
+	server := startServer()
+	defer server.Close()
+
+	var out bytes.Buffer
+	run(context.Background(), server, &out)
+
+	for _, header := range []string{
+		"=== QPS=5 Burst=10 (the defaults)\n",
+		"=== QPS=50 Burst=100\n",
+		"=== QPS=-1 (no rate limiter)\n",
+		"=== RateLimiter=paced(150ms)\n",
+		"=== rest.Config.Timeout=100ms\n",
+		"=== context.WithTimeout(100ms)\n",
+		"=== context.WithTimeout(500ms), QPS=1 Burst=1 (exhausted)\n",
+	} {
+		if !strings.Contains(out.String(), header) {
+			t.Errorf("expected %q in the output, got:\n%s", header, out.String())
+		}
+	}
+
+	// Only the lower bounds - a slow machine can make anything slower.
+	for name, min := range map[string]time.Duration{
+		// The bucket of 10 tokens, then one every 200ms.
+		"QPS=5 Burst=10 (the defaults)": 9 * 200 * time.Millisecond,
+		// Every request but the first one waits for its slot.
+		"RateLimiter=paced(150ms)": 19 * 150 * time.Millisecond,
+	} {
+		m := regexp.MustCompile(`(?s)=== ` + regexp.QuoteMeta(name) + `\n.*?\d+ requests in (\S+) `).FindStringSubmatch(out.String())
+		if m == nil {
+			t.Errorf("no timing for %q in the output", name)
+			continue
+		}
+		elapsed, err := time.ParseDuration(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed < min {
+			t.Errorf("expected %q to take at least %v, took %v", name, min, elapsed)
+		}
+	}
+
+	// The throttled requests of the defaults and the paced limiter (the
+	// other two don't wait long enough).
+	mu.Lock()
+	defer mu.Unlock()
+	if throttled < 19 {
+		t.Errorf("expected client-go to log at least 19 throttled requests, got %d", throttled)
+	}
+
+	if !strings.Contains(out.String(), "would exceed context deadline") {
+		t.Errorf("expected the exhausted rate limiter to fail right away, got:\n%s", out.String())
+	}
+}
+
+func TestPacedLimiter(t *testing.T) {
+	l := newPacedLimiter(50 * time.Millisecond)
+
+	if !l.TryAccept() {
+		t.Fatal("expected the first request to go right away")
+	}
+	if l.TryAccept() {
+		t.Fatal("expected the second request to wait")
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("expected 3 more requests to take at least 150ms, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("expected the slot past the deadline to fail the wait")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("expected the wait to fail right away, took %v", elapsed)
+	}
+}
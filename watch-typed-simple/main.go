@@ -2,89 +2,129 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path"
+	"flag"
 	"time"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 var (
 	namespace = "default"
-	label     = "watch-typed-simple-" + rand.String(6)
+	// Tells this run's objects from the ones of the concurrent runs.
+	runID = rand.String(6)
+
+	// How long to wait for the events to arrive before stopping the watch.
+	observeFor = 2 * time.Second
 )
 
 func main() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
 
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
+	lc := lifecycle.New("watch-typed-simple", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "watch-typed-simple"), config)
+	defer lc.Finish()
 
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	run(ctx, client)
+}
+
+// run returns the observed events, so the offline test can check them.
+func run(ctx context.Context, client kubernetes.Interface) []watch.Event {
 	// Create one object before starting to watch.
-	first := createConfigMap(client)
+	first := createConfigMap(ctx, client)
+
+	selector := "example==watch-typed-simple,example-run==" + runID
 
 	// Start watching. Expected events:
 	//  - ADDED the first config map (even though it was done before starting the watch)
 	//  - ADDED the second config map
 	//  - x2 DELETED
-	watch, err := client.
+	watcher, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Watch(
-			context.Background(),
+			ctx,
 			metav1.ListOptions{
-				LabelSelector: "example==" + label,
+				LabelSelector: selector,
 			},
 		)
 	if err != nil {
 		panic(err.Error())
 	}
+
+	logger := klog.FromContext(ctx).WithValues("labelSelector", selector)
+
+	var events []watch.Event
+	stopping := make(chan struct{})
+	done := make(chan struct{})
 	go func() {
-		for event := range watch.ResultChan() {
-			fmt.Printf(
-				"Watch Event: %s %s\n",
-				event.Type, event.Object.GetObjectKind().GroupVersionKind().Kind,
+		defer close(done)
+		for event := range watcher.ResultChan() {
+			select {
+			case <-stopping:
+				// Closing an HTTP/2 stream doesn't look like an EOF to
+				// client-go, so Stop() may surface as an ERROR event.
+				continue
+			default:
+			}
+
+			logger.Info(
+				"Watch event",
+				"type", event.Type,
+				"kind", event.Object.GetObjectKind().GroupVersionKind().Kind,
+				"resourceVersion", resourceVersion(event.Object),
 			)
+			events = append(events, event)
 		}
 	}()
 
 	// Create another object while watching.
-	second := createConfigMap(client)
+	second := createConfigMap(ctx, client)
 
-	deleteConfigMap(client, first)
-	deleteConfigMap(client, second)
+	deleteConfigMap(ctx, client, first)
+	deleteConfigMap(ctx, client, second)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(observeFor):
+	}
+	close(stopping)
+	watcher.Stop()
+	<-done
 
-	time.Sleep(2 * time.Second)
-	watch.Stop()
-	time.Sleep(1 * time.Second)
+	return events
 }
 
-func createConfigMap(client kubernetes.Interface) *corev1.ConfigMap {
+func createConfigMap(ctx context.Context, client kubernetes.Interface) *corev1.ConfigMap {
 	cm := &corev1.ConfigMap{Data: map[string]string{"foo": "bar"}}
 	cm.Namespace = namespace
 	cm.GenerateName = "watch-typed-simple-"
-	cm.SetLabels(map[string]string{"example": label})
+	cm.SetLabels(map[string]string{"example": "watch-typed-simple", "example-run": runID})
 
 	cm, err := client.
 		CoreV1().
 		ConfigMaps(namespace).
 		Create(
-			context.Background(),
+			ctx,
 			cm,
 			metav1.CreateOptions{},
 		)
@@ -92,16 +132,20 @@ func createConfigMap(client kubernetes.Interface) *corev1.ConfigMap {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.CoreV1().ConfigMaps(cm.GetNamespace()).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	})
 	return cm
 }
 
-func deleteConfigMap(client kubernetes.Interface, cm *corev1.ConfigMap) {
+func deleteConfigMap(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap) {
 	err := client.
 		CoreV1().
 		ConfigMaps(cm.GetNamespace()).
 		Delete(
-			context.Background(),
+			ctx,
 			cm.GetName(),
 			metav1.DeleteOptions{},
 		)
@@ -109,5 +153,15 @@ func deleteConfigMap(client kubernetes.Interface, cm *corev1.ConfigMap) {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Deleted ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
+}
+
+// resourceVersion returns "" for the objects without metadata, like the
+// *metav1.Status of ERROR events.
+func resourceVersion(obj runtime.Object) string {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return m.GetResourceVersion()
 }
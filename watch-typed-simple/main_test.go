@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/cassette"
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestRun(t *testing.T) {
+	observeFor = 100 * time.Millisecond
+
+	events := run(context.Background(), fakeclient.NewClientset())
+
+	var types []watch.EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	// Unlike the real API server, the fake one doesn't send the synthetic
+	// ADDED events for the objects that existed before the watch started.
+	want := []watch.EventType{watch.Added, watch.Deleted, watch.Deleted}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("unexpected watch events: got %v, want %v", types, want)
+	}
+}
+
+// TestRunCassette replays the traffic recorded against a real cluster.
+func TestRunCassette(t *testing.T) {
+	testRunCassette(t, "testdata/cassettes")
+}
+
+// TestRunFakeAPIServerCassette replays an extra fixture - the same run
+// recorded against the in-repo fakeapiserver rather than a real cluster.
+// Whatever KUBECONFIG says, it's (re-)recorded against an in-process fake.
+func TestRunFakeAPIServerCassette(t *testing.T) {
+	if cassette.Recording() {
+		server := fakeapiserver.NewServer()
+		t.Cleanup(server.Close)
+
+		kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+		if err := server.WriteKubeconfig(kubeconfig); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(clientcmd.RecommendedConfigPathEnvVar, kubeconfig)
+	}
+
+	testRunCassette(t, "testdata/cassettes/fakeapiserver")
+}
+
+func testRunCassette(t *testing.T, dir string) {
+	// The run ID ends up in the request URLs, so it must not change
+	// between the recording and the replay.
+	runID = "cassette"
+	if !cassette.Recording() {
+		observeFor = 100 * time.Millisecond
+	}
+
+	events := run(context.Background(), kubernetes.NewForConfigOrDie(cassette.Config(t, dir)))
+
+	var types []watch.EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	// The real API server does send ADDED for the pre-existing object.
+	want := []watch.EventType{watch.Added, watch.Added, watch.Deleted, watch.Deleted}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected watch events: got %v, want %v", types, want)
+	}
+
+	// Both ConfigMaps of this run are added, then deleted in the same order.
+	var names []string
+	for _, event := range events {
+		cm, ok := event.Object.(*corev1.ConfigMap)
+		if !ok {
+			t.Fatalf("expected a ConfigMap, got %T", event.Object)
+		}
+		if cm.Labels["example-run"] != runID {
+			t.Errorf("expected only the ConfigMaps of the run %s, got %s labeled %v", runID, cm.Name, cm.Labels)
+		}
+		names = append(names, cm.Name)
+	}
+	if names[0] == names[1] || names[0] != names[2] || names[1] != names[3] {
+		t.Errorf("expected the events for the first and the second ConfigMap, got %v", names)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	prog = "workqueue-finalizer"
+
+	// The finalizer keeps the ConfigMap around (with the deletionTimestamp
+	// set) until the controller cleans up its external counterpart.
+	// Finalizers not defined by Kubernetes itself must be domain-qualified.
+	finalizer = "client-go-examples.iximiuz.com/cleanup"
+)
+
+func main() {
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New(prog, lifecycle.ConfigMaps)
+	lc.StripFinalizers(finalizer)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), prog), config)
+	defer lc.Finish()
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	run(ctx, client, "default", newRegistry())
+}
+
+// run starts the controller, creates a few ConfigMaps, waits for them to
+// show up in the registry, then deletes them and waits for the controller
+// to let them go.
+func run(ctx context.Context, client kubernetes.Interface, namespace string, reg *registry) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := startController(ctx, client, namespace, reg)
+	defer c.queue.ShutDown()
+
+	var cms []*corev1.ConfigMap
+	for i := 0; i < 3; i++ {
+		cms = append(cms, createConfigMap(ctx, client, namespace))
+	}
+
+	waitFor(ctx, "the ConfigMaps to be registered", func() bool {
+		for _, cm := range cms {
+			if _, ok := reg.Get(key(cm)); !ok {
+				return false
+			}
+		}
+		return true
+	})
+	klog.FromContext(ctx).Info("Registered ConfigMaps", "count", reg.Len())
+
+	for _, cm := range cms {
+		deleteConfigMap(ctx, client, cm)
+	}
+
+	// The DELETE requests return right away - the ConfigMaps are gone
+	// only once the controller removes its finalizer.
+	waitFor(ctx, "the ConfigMaps to be cleaned up", func() bool {
+		for _, cm := range cms {
+			_, err := client.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
+			if !apierrors.IsNotFound(err) {
+				return false
+			}
+		}
+		return reg.Len() == 0
+	})
+	klog.FromContext(ctx).Info("Cleaned up ConfigMaps", "count", len(cms))
+}
+
+type controller struct {
+	client   kubernetes.Interface
+	lister   corelisters.ConfigMapLister
+	queue    workqueue.RateLimitingInterface
+	registry *registry
+}
+
+// startController starts an informer watching the program's ConfigMaps
+// and two workers reconciling them. Everything stops when ctx is done.
+func startController(ctx context.Context, client kubernetes.Interface, namespace string, reg *registry) *controller {
+	// The controller adds finalizers, so it'd better not touch anybody
+	// else's ConfigMaps.
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 30*time.Second,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = lifecycle.Label + "=" + prog
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps()
+
+	c := &controller{
+		client:   client,
+		lister:   informer.Lister(),
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		registry: reg,
+	}
+
+	// A ConfigMap with a finalizer isn't deleted but updated (its
+	// deletionTimestamp gets set), so the DELETE events only come after
+	// the cleanup. The handler enqueues them anyway - reconciling
+	// a missing ConfigMap is a no-op.
+	enqueue := func(obj interface{}) {
+		if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, new interface{}) { enqueue(new) },
+		DeleteFunc: enqueue,
+	})
+
+	factory.Start(ctx.Done())
+	for typ, ok := range factory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			panic(fmt.Sprintf("Failed to sync cache for %v", typ))
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		go wait.UntilWithContext(klog.NewContext(ctx, klog.FromContext(ctx).WithValues("worker", i)), c.worker, time.Second)
+	}
+	return c
+}
+
+// worker processes the queue until it's shut down. The failed keys are
+// re-enqueued with an exponential backoff - and never given up on: the
+// ConfigMaps being deleted would be stuck with the finalizer otherwise.
+func (c *controller) worker(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+
+	for {
+		key, quit := c.queue.Get()
+		if quit {
+			return
+		}
+
+		func() {
+			defer c.queue.Done(key)
+
+			if err := c.reconcile(ctx, key.(string)); err != nil {
+				logger.Error(err, "Failed to reconcile ConfigMap, putting it back to the queue", "key", key, "requeues", c.queue.NumRequeues(key))
+				c.queue.AddRateLimited(key)
+				return
+			}
+			c.queue.Forget(key)
+		}()
+	}
+}
+
+// reconcile brings the registry in line with the ConfigMap. It's safe to
+// call any number of times for the same key, with the informer's cache
+// lagging behind or not:
+//
+//   - a live ConfigMap gets the finalizer first, then its data is put into
+//     the registry (again and again - it's a no-op when nothing changed);
+//   - a ConfigMap being deleted is removed from the registry (a no-op when
+//     it's not there), then the finalizer is removed;
+//   - a missing ConfigMap needs nothing - the finalizer guarantees it was
+//     cleaned up before it was gone.
+func (c *controller) reconcile(ctx context.Context, key string) error {
+	logger := klog.FromContext(ctx).WithValues("key", key)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	cm, err := c.lister.ConfigMaps(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.V(4).Info("ConfigMap is gone")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.DeletionTimestamp.IsZero() {
+		if !slices.Contains(cm.Finalizers, finalizer) {
+			// The update event brings the ConfigMap back to the queue.
+			logger.Info("Adding finalizer")
+			return c.patchFinalizers(ctx, cm, append(slices.Clone(cm.Finalizers), finalizer))
+		}
+
+		if err := c.registry.Put(key, cm.Data); err != nil {
+			return err
+		}
+		logger.V(4).Info("Registered ConfigMap")
+		return nil
+	}
+
+	// Being deleted, but not waiting for us (anymore).
+	if !slices.Contains(cm.Finalizers, finalizer) {
+		return nil
+	}
+
+	if err := c.registry.Delete(key); err != nil {
+		return err
+	}
+
+	logger.Info("Cleaned up, removing finalizer")
+	err = c.patchFinalizers(ctx, cm, slices.DeleteFunc(slices.Clone(cm.Finalizers), func(f string) bool {
+		return f == finalizer
+	}))
+	if apierrors.IsNotFound(err) {
+		// Reconciled with a stale cache - removed already.
+		return nil
+	}
+	return err
+}
+
+// patchFinalizers replaces the finalizers of the ConfigMap. A merge patch
+// replaces lists as a whole, so the resourceVersion makes sure nobody
+// else changed them in between - otherwise the patch fails with 409
+// Conflict, and the key is retried once the cache catches up.
+func (c *controller) patchFinalizers(ctx context.Context, cm *corev1.ConfigMap, finalizers []string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": cm.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.CoreV1().ConfigMaps(cm.Namespace).Patch(ctx, cm.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// registry stands in for the external system the controller keeps in sync
+// with the ConfigMaps - a DNS zone, a bucket, a row in a database, etc.
+// The nil fail func never fails - the tests replace it to make the
+// registry flaky.
+type registry struct {
+	mu      sync.Mutex
+	entries map[string]map[string]string
+	fail    func(op, key string) error
+}
+
+func newRegistry() *registry {
+	return &registry{entries: map[string]map[string]string{}}
+}
+
+func (r *registry) Put(key string, data map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fail != nil {
+		if err := r.fail("put", key); err != nil {
+			return err
+		}
+	}
+	// The data may come from the informer's cache - never keep it.
+	r.entries[key] = maps.Clone(data)
+	return nil
+}
+
+// Delete doesn't fail for the missing keys - the cleanup may be retried
+// after it's done, e.g., when removing the finalizer fails.
+func (r *registry) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.fail != nil {
+		if err := r.fail("delete", key); err != nil {
+			return err
+		}
+	}
+	delete(r.entries, key)
+	return nil
+}
+
+func (r *registry) Get(key string) (map[string]string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, ok := r.entries[key]
+	return data, ok
+}
+
+func (r *registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.entries)
+}
+
+func createConfigMap(ctx context.Context, client kubernetes.Interface, namespace string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prog + "-",
+			Namespace:    namespace,
+			Labels:       map[string]string{lifecycle.Label: prog},
+		},
+		Data: map[string]string{"foo": "bar"},
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.ResourceVersion)
+
+	// If the program dies before the controller lets the ConfigMap go,
+	// deleting it isn't enough - nobody would remove the finalizer.
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		patch := []byte(`{"metadata":{"finalizers":null}}`)
+		if _, err := client.CoreV1().ConfigMaps(cm.Namespace).Patch(ctx, cm.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+		return client.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+	})
+	return cm
+}
+
+func deleteConfigMap(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap) {
+	err := client.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	klog.FromContext(ctx).Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
+}
+
+// waitFor polls the condition until it's true, or panics after a while.
+func waitFor(ctx context.Context, what string, condition func() bool) {
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true, func(context.Context) (bool, error) {
+		return condition(), nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Timed out waiting for %s: %v", what, err))
+	}
+}
+
+func key(cm *corev1.ConfigMap) string {
+	return cm.Namespace + "/" + cm.Name
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRun(t *testing.T) {
+	client := fakeclient.NewClientset()
+
+	lc := lifecycle.New(prog)
+	ctx := lifecycle.NewContext(context.Background(), lc)
+
+	// Every registry call fails the first time - the workers retry.
+	var (
+		mu     sync.Mutex
+		failed = map[string]bool{}
+	)
+	reg := newRegistry()
+	reg.fail = func(op, key string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failed[op+" "+key] {
+			return nil
+		}
+		failed[op+" "+key] = true
+		return errors.New("registry is unavailable")
+	}
+
+	run(ctx, client, "default", reg)
+
+	// Adding and removing the finalizer, once per ConfigMap - counted
+	// before the cleanup (finding nothing to clean up) patches again.
+	var patches int
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+
+	lc.Finish()
+
+	if len(failed) != 6 {
+		t.Errorf("expected a failed put and delete for each of the 3 ConfigMaps, got %v", failed)
+	}
+	if patches != 6 {
+		t.Errorf("expected 6 patches, got %d", patches)
+	}
+
+	list, err := client.CoreV1().ConfigMaps("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMaps to be deleted, found %d", len(list.Items))
+	}
+}
+
+// TestReconcile calls reconcile directly, feeding it the ConfigMap as
+// the informer's cache would see it - up to date or not.
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+
+	client := fakeclient.NewClientset()
+	cm, err := client.CoreV1().ConfigMaps("default").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"},
+		Data:       map[string]string{"foo": "bar"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	c := &controller{
+		client:   client,
+		lister:   corelisters.NewConfigMapLister(indexer),
+		registry: newRegistry(),
+	}
+
+	// refresh puts the current ConfigMap into the cache.
+	refresh := func() *corev1.ConfigMap {
+		cm := get(t, client)
+		indexer.Update(cm)
+		return cm
+	}
+	reconcile := func() error {
+		return c.reconcile(ctx, "default/cm")
+	}
+
+	indexer.Add(cm)
+	if err := reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if cm = get(t, client); !slices.Contains(cm.Finalizers, finalizer) {
+		t.Fatalf("expected the finalizer to be added, got %v", cm.Finalizers)
+	}
+
+	// The cache doesn't have the finalizer yet - adding it again must fail
+	// rather than overwrite the changes made in between.
+	if err := reconcile(); !apierrors.IsConflict(err) {
+		t.Fatalf("expected a conflict reconciling a stale ConfigMap, got %v", err)
+	}
+
+	refresh()
+	for i := 0; i < 2; i++ {
+		if err := reconcile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok := c.registry.Get("default/cm"); !ok || data["foo"] != "bar" {
+		t.Fatalf("expected the ConfigMap to be registered, got %v", data)
+	}
+
+	if err := client.CoreV1().ConfigMaps("default").Delete(ctx, "cm", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if cm = get(t, client); cm.DeletionTimestamp.IsZero() {
+		t.Fatal("expected the ConfigMap with the finalizer to stay, with the deletionTimestamp set")
+	}
+
+	// A failed cleanup keeps the finalizer.
+	c.registry.fail = func(op, key string) error {
+		return errors.New("registry is unavailable")
+	}
+	stale := refresh()
+	if err := reconcile(); err == nil {
+		t.Fatal("expected the failed cleanup to fail the reconcile")
+	}
+	if cm = get(t, client); !slices.Contains(cm.Finalizers, finalizer) {
+		t.Fatalf("expected the finalizer to stay, got %v", cm.Finalizers)
+	}
+
+	c.registry.fail = nil
+	if err := reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.registry.Get("default/cm"); ok {
+		t.Error("expected the ConfigMap to be unregistered")
+	}
+	if _, err := client.CoreV1().ConfigMaps("default").Get(ctx, "cm", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the ConfigMap to be deleted once the finalizer is removed, got %v", err)
+	}
+
+	// The cache still has the ConfigMap being deleted - the cleanup
+	// is repeated, and removing the finalizer finds nothing to patch.
+	if err := reconcile(); err != nil {
+		t.Fatalf("expected reconciling a stale ConfigMap to succeed, got %v", err)
+	}
+
+	indexer.Delete(stale)
+	if err := reconcile(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, client kubernetes.Interface) *corev1.ConfigMap {
+	t.Helper()
+
+	cm, err := client.CoreV1().ConfigMaps("default").Get(context.Background(), "cm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm
+}
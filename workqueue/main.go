@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"os"
-	"path"
 	"time"
 
+	"github.com/iximiuz/client-go-examples/bootstrap"
+	"github.com/iximiuz/client-go-examples/lifecycle"
+	"github.com/iximiuz/client-go-examples/logging"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
 )
 
 var (
@@ -24,11 +28,27 @@ var (
 		Version:  "v1",
 		Resource: "configmaps",
 	}
+
+	// How long to let the workers process the events. The offline test shortens it.
+	observeFor = 10 * time.Second
 )
 
 func main() {
-	client := createClientOrDie()
+	var logs logging.Options
+	logs.AddFlags(flag.CommandLine)
+
+	lc := lifecycle.New("workqueue", lifecycle.ConfigMaps)
+	lc.AddFlags(flag.CommandLine)
+
+	config := bootstrap.ConfigOrDie()
+
+	ctx := lc.Start(logs.NewContext(context.Background(), "workqueue"), config)
+	defer lc.Finish()
+
+	run(ctx, createClientOrDie(config))
+}
 
+func run(ctx context.Context, client dynamic.Interface) {
 	// The work queue has the following properties:
 	//   - Fair: items processed in the order in which they are added.
 	//   - Stingy: a single item will not be processed multiple times concurrently,
@@ -51,6 +71,10 @@ func main() {
 	)
 	dynamicInformer := factory.ForResource(ConfigMapResource)
 
+	// The event handlers and the workers below log with the logger from
+	// the context, so every line carries the program name and the resource.
+	logger := klog.FromContext(ctx).WithValues("gvr", ConfigMapResource.String())
+
 	// Informer watches a resource (ConfigMap in this particular example)
 	// and simply pushes object keys to the queue.
 	dynamicInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -58,14 +82,14 @@ func main() {
 			// key is a string <namespace>/<name> (or just <name> for cluster-wide objects)
 			key, err := cache.MetaNamespaceKeyFunc(obj)
 			if err == nil {
-				fmt.Printf("New event: ADD %s\n", key)
+				logger.Info("New event", "type", "ADD", "key", key, "resourceVersion", resourceVersion(obj))
 				queue.Add(key)
 			}
 		},
 		UpdateFunc: func(old, new interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(new)
 			if err == nil {
-				fmt.Printf("New event: UPDATE %s\n", key)
+				logger.Info("New event", "type", "UPDATE", "key", key, "resourceVersion", resourceVersion(new))
 				queue.Add(key)
 			}
 		},
@@ -73,13 +97,13 @@ func main() {
 			// much like cache.MetaNamespaceKeyFunc + some extra check.
 			key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			if err == nil {
-				fmt.Printf("New event: DELETE %s\n", key)
+				logger.Info("New event", "type", "DELETE", "key", key, "resourceVersion", resourceVersion(obj))
 				queue.Add(key)
 			}
 		},
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Start the informers' machinery.
@@ -97,15 +121,18 @@ func main() {
 	for i := 0; i < 3; i++ {
 		// A better way is to use wait.Until() from "k8s.io/apimachinery/pkg/util/wait"
 		// for every worker.
-		fmt.Printf("Starting worker %d\n", i)
+		logger.Info("Starting worker", "worker", i)
 
 		// worker()
 		go func(n int) {
+			// Every line the worker logs carries its number.
+			logger := logger.WithValues("worker", n)
+
 			for {
 				// Someone said we're done?
 				select {
 				case <-ctx.Done():
-					fmt.Printf("Controller's done! Worker %d exiting...\n", n)
+					logger.Info("Controller's done, worker exiting")
 					return
 				default:
 				}
@@ -113,10 +140,10 @@ func main() {
 				// Obtain a piece of work.
 				key, quit := queue.Get()
 				if quit {
-					fmt.Printf("Work queue has been shut down! Worker %d exiting...\n", n)
+					logger.Info("Work queue has been shut down, worker exiting")
 					return
 				}
-				fmt.Printf("Worker %d is about to start process new item %s.\n", n, key)
+				logger.Info("Processing item", "key", key)
 
 				// processSingleItem() - scoped to utilize defer and premature returns.
 				func() {
@@ -129,13 +156,13 @@ func main() {
 					// YOUR CONTROLLER'S BUSINESS LOGIC GOES HERE
 					obj, err := dynamicInformer.Lister().Get(key.(string))
 					if err == nil {
-						fmt.Printf("Worker %d found ConfigMap object in informer's cahce %#v.\n", n, obj)
+						logger.V(4).Info("Found ConfigMap in the informer's cache", "key", key, "resourceVersion", resourceVersion(obj))
 						// RECONCILE THE OBJECT - PUT YOUR BUSINESS LOGIC HERE.
 						if n == 1 {
 							err = fmt.Errorf("worker %d is a chronic failure", n)
 						}
 					} else {
-						fmt.Printf("Worker %d got error %v while looking up ConfigMap object in informer's cache.\n", n, err)
+						logger.Info("ConfigMap is gone from the informer's cache", "key", key, "reason", err.Error())
 					}
 
 					// Handle the error if something went wrong during the execution of
@@ -145,14 +172,14 @@ func main() {
 						// The key has been handled successfully - forget about it. In particular, it
 						// ensures that future processing of updates for this key won't be rate limited
 						// because of errors on previous attempts.
-						fmt.Printf("Worker %d reconciled ConfigMap %s successfully. Removing it from te queue.\n", n, key)
+						logger.Info("Reconciled ConfigMap, removing it from the queue", "key", key)
 						queue.Forget(key)
 						return
 					}
 
 					// We retry no more than K=5 times.
 					if queue.NumRequeues(key) >= 5 {
-						fmt.Printf("Worker %d gave up on processing %s. Removing it from the queue.\n", n, key)
+						logger.Error(err, "Giving up on ConfigMap, removing it from the queue", "key", key, "requeues", queue.NumRequeues(key))
 						queue.Forget(key)
 						return
 					}
@@ -161,7 +188,7 @@ func main() {
 					// Notice that deferred queue.Done(key) call above knows how
 					// to deal with re-enqueueing - it marks the key as done and
 					// then re-appends it again.
-					fmt.Printf("Worker %d failed to process %s. Putting it back to the queue to retry later.\n", n, key)
+					logger.Error(err, "Failed to process ConfigMap, putting it back to the queue", "key", key, "requeues", queue.NumRequeues(key))
 					queue.AddRateLimited(key)
 				}()
 			}
@@ -169,37 +196,30 @@ func main() {
 	}
 
 	// Create some Kubernetes objects to make the above program actually process something.
-	cm1 := createConfigMap(client)
-	cm2 := createConfigMap(client)
-	cm3 := createConfigMap(client)
-	cm4 := createConfigMap(client)
-	cm5 := createConfigMap(client)
+	cm1 := createConfigMap(ctx, client)
+	cm2 := createConfigMap(ctx, client)
+	cm3 := createConfigMap(ctx, client)
+	cm4 := createConfigMap(ctx, client)
+	cm5 := createConfigMap(ctx, client)
 
 	// Delete config maps created by this test.
-	deleteConfigMap(client, cm1)
-	deleteConfigMap(client, cm2)
-	deleteConfigMap(client, cm3)
-	deleteConfigMap(client, cm4)
-	deleteConfigMap(client, cm5)
+	deleteConfigMap(ctx, client, cm1)
+	deleteConfigMap(ctx, client, cm2)
+	deleteConfigMap(ctx, client, cm3)
+	deleteConfigMap(ctx, client, cm4)
+	deleteConfigMap(ctx, client, cm5)
 
 	// Stay for a couple more seconds to let the program finish.
-	time.Sleep(10 * time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(observeFor):
+	}
 	queue.ShutDown()
 	cancel()
 	time.Sleep(1 * time.Second)
 }
 
-func createClientOrDie() dynamic.Interface {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
-
-	config, err := clientcmd.BuildConfigFromFlags("", path.Join(home, ".kube/config"))
-	if err != nil {
-		panic(err.Error())
-	}
-
+func createClientOrDie(config *rest.Config) dynamic.Interface {
 	client, err := dynamic.NewForConfig(config)
 	if err != nil {
 		panic(err.Error())
@@ -208,7 +228,7 @@ func createClientOrDie() dynamic.Interface {
 	return client
 }
 
-func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
+func createConfigMap(ctx context.Context, client dynamic.Interface) *unstructured.Unstructured {
 	cm := &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "v1",
@@ -216,6 +236,9 @@ func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
 			"metadata": map[string]interface{}{
 				"namespace":    namespace,
 				"generateName": "workqueue-",
+				"labels": map[string]interface{}{
+					"example": "workqueue",
+				},
 			},
 			"data": map[string]interface{}{
 				"foo": "bar",
@@ -226,26 +249,37 @@ func createConfigMap(client dynamic.Interface) *unstructured.Unstructured {
 	cm, err := client.
 		Resource(ConfigMapResource).
 		Namespace(namespace).
-		Create(context.Background(), cm, metav1.CreateOptions{})
+		Create(ctx, cm, metav1.CreateOptions{})
 	if err != nil {
 		panic(err.Error())
 	}
 
-	fmt.Printf("Created ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	klog.FromContext(ctx).Info("Created ConfigMap", "configMap", klog.KObj(cm), "resourceVersion", cm.GetResourceVersion())
+
+	lifecycle.Track(ctx, "ConfigMap", cm, func(ctx context.Context) error {
+		return client.Resource(ConfigMapResource).Namespace(cm.GetNamespace()).Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
+	})
 	return cm
 }
 
-func deleteConfigMap(client dynamic.Interface, cm *unstructured.Unstructured) {
+func deleteConfigMap(ctx context.Context, client dynamic.Interface, cm *unstructured.Unstructured) {
 	err := client.
 		Resource(ConfigMapResource).
 		Namespace(cm.GetNamespace()).
-		Delete(context.Background(), cm.GetName(), metav1.DeleteOptions{})
+		Delete(ctx, cm.GetName(), metav1.DeleteOptions{})
 	if err != nil {
 		panic(err.Error())
 	}
+
+	klog.FromContext(ctx).Info("Deleted ConfigMap", "configMap", klog.KObj(cm))
+}
+
+// resourceVersion returns "" for the tombstones (cache.DeletedFinalStateUnknown)
+// the delete handler may get instead of the objects.
+func resourceVersion(obj interface{}) string {
+	m, err := meta.Accessor(obj)
 	if err != nil {
-		panic(err.Error())
+		return ""
 	}
-
-	fmt.Printf("Deleted ConfigMap %s/%s\n", cm.GetNamespace(), cm.GetName())
+	return m.GetResourceVersion()
 }
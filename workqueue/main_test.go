@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/iximiuz/client-go-examples/cassette"
+	"github.com/iximiuz/client-go-examples/fakeapiserver"
+	"github.com/iximiuz/client-go-examples/fakeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+func TestRun(t *testing.T) {
+	// Enough for the chronically failing worker to exhaust its retries.
+	observeFor = time.Second
+
+	client := fakeclient.NewDynamicClient()
+
+	run(context.Background(), client)
+
+	var creates, deletes int
+	for _, action := range client.Actions() {
+		switch action.GetVerb() {
+		case "create":
+			creates++
+		case "delete":
+			deletes++
+		}
+	}
+	if creates != 5 || deletes != 5 {
+		t.Errorf("expected 5 ConfigMaps created and deleted, got %d and %d", creates, deletes)
+	}
+
+	list, err := client.Resource(ConfigMapResource).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected the ConfigMaps to be deleted, found %d", len(list.Items))
+	}
+}
+
+// captureLogs returns a context carrying a JSON logger and a func
+// returning the lines logged so far.
+func captureLogs(t *testing.T) (context.Context, func() []map[string]interface{}) {
+	var (
+		mu    sync.Mutex
+		lines []map[string]interface{}
+	)
+	logger := funcr.NewJSON(func(obj string) {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(obj), &line); err != nil {
+			t.Errorf("not a JSON log line %q: %v", obj, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, line)
+	}, funcr.Options{})
+
+	return klog.NewContext(context.Background(), logger), func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(lines)
+	}
+}
+
+func TestRunLogs(t *testing.T) {
+	observeFor = time.Second
+
+	ctx, logged := captureLogs(t)
+	run(ctx, fakeclient.NewDynamicClient())
+
+	var processed, added int
+	for _, line := range logged() {
+		switch line["msg"] {
+		case "Processing item":
+			processed++
+			if line["gvr"] != ConfigMapResource.String() || line["key"] == nil || line["worker"] == nil {
+				t.Errorf("expected the gvr, key, and worker to be logged, got %v", line)
+			}
+		case "New event":
+			if line["type"] != "ADD" {
+				continue
+			}
+			added++
+			if line["key"] == nil || line["resourceVersion"] == "" {
+				t.Errorf("expected the key and resourceVersion to be logged, got %v", line)
+			}
+		}
+	}
+	if processed == 0 || added != 5 {
+		t.Errorf("expected 5 ADD events and some items processed, got %d and %d", added, processed)
+	}
+}
+
+// TestRunCassette replays the traffic recorded against a real cluster.
+func TestRunCassette(t *testing.T) {
+	testRunCassette(t, "testdata/cassettes")
+}
+
+// TestRunFakeAPIServerCassette replays an extra fixture - the same run
+// recorded against the in-repo fakeapiserver rather than a real cluster.
+// Whatever KUBECONFIG says, it's (re-)recorded against an in-process fake.
+func TestRunFakeAPIServerCassette(t *testing.T) {
+	if cassette.Recording() {
+		server := fakeapiserver.NewServer()
+		t.Cleanup(server.Close)
+
+		kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+		if err := server.WriteKubeconfig(kubeconfig); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(clientcmd.RecommendedConfigPathEnvVar, kubeconfig)
+	}
+
+	testRunCassette(t, "testdata/cassettes/fakeapiserver")
+}
+
+func testRunCassette(t *testing.T, dir string) {
+	observeFor = time.Second
+	if cassette.Recording() {
+		observeFor = 10 * time.Second
+	}
+
+	ctx, logged := captureLogs(t)
+	run(ctx, dynamic.NewForConfigOrDie(cassette.Config(t, dir)))
+
+	// The keys of the ConfigMaps run() has created (the names come from
+	// the recorded responses), and what the informer and the workers
+	// made of them.
+	var created []string
+	events := map[string][]string{}
+	processed := map[string]bool{}
+	for _, line := range logged() {
+		switch line["msg"] {
+		case "Created ConfigMap":
+			created = append(created, namespace+"/"+line["configMap"].(map[string]interface{})["name"].(string))
+		case "New event":
+			key := line["key"].(string)
+			events[key] = append(events[key], line["type"].(string))
+		case "Processing item":
+			processed[line["key"].(string)] = true
+		}
+	}
+
+	if len(created) != 5 {
+		t.Fatalf("expected 5 ConfigMaps created, got %v", created)
+	}
+	for _, key := range created {
+		if want := []string{"ADD", "DELETE"}; !slices.Equal(events[key], want) {
+			t.Errorf("expected the events %v for %s, got %v", want, key, events[key])
+		}
+		if !processed[key] {
+			t.Errorf("expected %s to be processed by a worker", key)
+		}
+	}
+}